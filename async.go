@@ -0,0 +1,143 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultQueueSize    = 128
+	defaultQueueWorkers = 4
+)
+
+// AsyncErrorHandler is called with the error of an event dispatched from the
+// async queue, since there is no caller left to return it to.
+type AsyncErrorHandler func(ctx context.Context, event any, err error)
+
+type asyncJob struct {
+	ctx   context.Context
+	event any
+}
+
+type asyncQueue struct {
+	mu     sync.RWMutex
+	closed bool
+	jobs   chan asyncJob
+	wg     sync.WaitGroup
+}
+
+// WithAsyncQueue configures the queue used by Enqueue and EnqueueRequest:
+// at most size events wait for one of workers goroutines. Without this option
+// a queue of 128 events and 4 workers is created on first use.
+func WithAsyncQueue(size, workers int) Option {
+	return func(d *Dispatcher) {
+		d.queueSize, d.queueWorkers = size, workers
+	}
+}
+
+// WithAsyncErrorHandler sets the handler for errors of asynchronously
+// dispatched events. They are dropped by default.
+func WithAsyncErrorHandler(handler AsyncErrorHandler) Option {
+	return func(d *Dispatcher) {
+		d.asyncErrorHandler = handler
+	}
+}
+
+// Enqueue queues event to be dispatched in the background and returns
+// immediately. Listeners receive a context that keeps the values of ctx but
+// is never cancelled. It returns ErrQueueFull when the queue is at capacity
+// and ErrDispatcherClosed after Shutdown.
+func (d *Dispatcher) Enqueue(ctx context.Context, event any) error {
+	q := d.asyncQueue()
+
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrDispatcherClosed
+	}
+
+	select {
+	case q.jobs <- asyncJob{ctx: context.WithoutCancel(ctx), event: event}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// EnqueueRequest validates and decodes req like DispatchRequest, then queues
+// the event with Enqueue.
+func (d *Dispatcher) EnqueueRequest(req *http.Request, opts ...DispatchRequestOption) error {
+	ctx, event, err := d.parseRequest(req, newDispatchRequestOptions(req, opts...))
+	if errors.Is(err, errNoListeners) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := d.Enqueue(ctx, event); err != nil {
+		return errors.Join(err, releaseDelivery(ctx))
+	}
+	return nil
+}
+
+// Shutdown stops accepting queued events and waits until the events already
+// queued have been dispatched, or until ctx is done.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+	q := d.asyncQueue()
+
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if d.pool != nil {
+			d.pool.close()
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *Dispatcher) asyncQueue() *asyncQueue {
+	d.queueOnce.Do(func() {
+		size, workers := d.queueSize, d.queueWorkers
+		if size <= 0 {
+			size = defaultQueueSize
+		}
+		if workers <= 0 {
+			workers = defaultQueueWorkers
+		}
+
+		q := &asyncQueue{jobs: make(chan asyncJob, size)}
+		q.wg.Add(workers)
+		for range workers {
+			go func() {
+				defer q.wg.Done()
+				for job := range q.jobs {
+					err := d.Dispatch(job.ctx, job.event)
+					if err != nil {
+						err = errors.Join(err, releaseDelivery(job.ctx))
+					}
+					if err != nil && d.asyncErrorHandler != nil {
+						d.asyncErrorHandler(job.ctx, job.event, err)
+					}
+				}
+			}()
+		}
+		d.queue = q
+	})
+	return d.queue
+}
@@ -0,0 +1,106 @@
+package gitlabwebhook
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type blockingPushListener struct {
+	started chan struct{}
+	release chan struct{}
+	done    chan error
+}
+
+func newBlockingPushListener() *blockingPushListener {
+	return &blockingPushListener{
+		started: make(chan struct{}, 8),
+		release: make(chan struct{}),
+		done:    make(chan error, 8),
+	}
+}
+
+func (l *blockingPushListener) OnPush(ctx context.Context, _ *gitlab.PushEvent) error {
+	l.started <- struct{}{}
+	<-l.release
+	l.done <- ctx.Err()
+	return nil
+}
+
+func newPushRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(loadFixture("testdata/webhooks/push.json")))
+	req.Header.Set("X-Gitlab-Event", string(gitlab.EventTypePush))
+	return req
+}
+
+func TestDispatcher_HandlerAsync(t *testing.T) {
+	listener := newBlockingPushListener()
+	dispatcher := NewDispatcher(WithAsyncQueue(1, 1), RegisterListeners(listener))
+	handler := dispatcher.Handler(HandlerAsync(), HandlerWithRetryAfter(30*time.Second))
+
+	// acknowledged before the listener has finished
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newPushRequest(t).WithContext(ctx))
+	cancel()
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	<-listener.started
+
+	// the single queue slot is free while the worker is busy
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newPushRequest(t))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	// queue is full
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newPushRequest(t))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "30", rec.Header().Get("Retry-After"))
+
+	// shutdown drains both queued deliveries
+	close(listener.release)
+	require.NoError(t, dispatcher.Shutdown(context.Background()))
+	assert.Len(t, listener.done, 2)
+	for range 2 {
+		assert.NoError(t, <-listener.done, "listener context must be detached from the request")
+	}
+
+	assert.ErrorIs(t, dispatcher.Enqueue(context.Background(), &gitlab.PushEvent{}), ErrDispatcherClosed)
+}
+
+func TestDispatcher_AsyncErrorHandler(t *testing.T) {
+	errs := make(chan error, 1)
+	dispatcher := NewDispatcher(
+		RegisterListeners(failingPushListener{}),
+		WithAsyncErrorHandler(func(_ context.Context, event any, err error) {
+			assert.IsType(t, &gitlab.PushEvent{}, event)
+			errs <- err
+		}),
+	)
+
+	require.NoError(t, dispatcher.EnqueueRequest(newPushRequest(t)))
+	require.NoError(t, dispatcher.Shutdown(context.Background()))
+	assert.EqualError(t, <-errs, "downstream unavailable")
+}
+
+func TestDispatcher_ShutdownTimeout(t *testing.T) {
+	listener := newBlockingPushListener()
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+	require.NoError(t, dispatcher.Enqueue(context.Background(), &gitlab.PushEvent{}))
+	<-listener.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, dispatcher.Shutdown(ctx), context.DeadlineExceeded)
+
+	close(listener.release)
+	assert.NoError(t, dispatcher.Shutdown(context.Background()))
+}
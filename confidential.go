@@ -0,0 +1,45 @@
+package gitlabwebhook
+
+import (
+	"context"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type confidentialContextKey struct{}
+
+// WithConfidentialListenersOnly passes confidential issues and notes only to
+// ConfidentialIssueListener and ConfidentialNoteListener. By default they
+// reach IssueListener and IssueCommentListener too, which can tell them apart
+// with IsConfidential.
+func WithConfidentialListenersOnly() Option {
+	return func(d *Dispatcher) {
+		d.confidentialListenersOnly = true
+	}
+}
+
+// IsConfidential reports whether the event being dispatched with ctx came
+// from a Confidential Issue Hook or Confidential Note Hook, or, for events
+// passed to Dispatch directly, belongs to a confidential issue.
+func IsConfidential(ctx context.Context) bool {
+	confidential, _ := ctx.Value(confidentialContextKey{}).(bool)
+	return confidential
+}
+
+func withConfidential(ctx context.Context) context.Context {
+	if IsConfidential(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, confidentialContextKey{}, true)
+}
+
+func withoutConfidential(ctx context.Context) context.Context {
+	if !IsConfidential(ctx) {
+		return ctx
+	}
+	return context.WithValue(ctx, confidentialContextKey{}, false)
+}
+
+func isConfidentialEventType(eventType gitlab.EventType) bool {
+	return eventType == gitlab.EventConfidentialIssue || eventType == gitlab.EventConfidentialNote
+}
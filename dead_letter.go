@@ -0,0 +1,128 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// DeadLetter records a delivery one of whose listeners failed for good.
+type DeadLetter struct {
+	EventType gitlab.EventType `json:"event_type"`
+	// Payload is the raw webhook body, or the JSON encoded event when it was
+	// passed to Dispatch directly.
+	Payload json.RawMessage `json:"payload"`
+	// Header holds the request headers when the event came from DispatchRequest.
+	Header       http.Header `json:"header,omitempty"`
+	ListenerType string      `json:"listener_type"`
+	Error        string      `json:"error"`
+	FailedAt     time.Time   `json:"failed_at"`
+
+	// Err is the final error of the listener. It is not persisted.
+	Err error `json:"-"`
+}
+
+// DeadLetterSink receives deliveries whose listeners failed after all retries.
+type DeadLetterSink interface {
+	DeadLetter(ctx context.Context, letter *DeadLetter) error
+}
+
+// DeadLetterSinkFunc is an adapter to allow the use of ordinary functions as a DeadLetterSink.
+type DeadLetterSinkFunc func(ctx context.Context, letter *DeadLetter) error
+
+func (f DeadLetterSinkFunc) DeadLetter(ctx context.Context, letter *DeadLetter) error {
+	return f(ctx, letter)
+}
+
+// WithDeadLetterSink sends every failed listener invocation to sink. Errors of
+// the sink are joined into the dispatch error.
+func WithDeadLetterSink(sink DeadLetterSink) Option {
+	return func(d *Dispatcher) {
+		d.deadLetterSink = sink
+	}
+}
+
+// FileDeadLetterSink appends dead letters to a file, one JSON document per line.
+type FileDeadLetterSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileDeadLetterSink(path string) *FileDeadLetterSink {
+	return &FileDeadLetterSink{path: path}
+}
+
+func (s *FileDeadLetterSink) DeadLetter(_ context.Context, letter *DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReplayDeadLetters reads dead letters as written by FileDeadLetterSink and
+// dispatches each of them again with DispatchWebhook. Note that every listener
+// of the event type receives the replayed event, not only the one that failed.
+func (d *Dispatcher) ReplayDeadLetters(ctx context.Context, r io.Reader) error {
+	decoder := json.NewDecoder(r)
+
+	var errs []error
+	for {
+		var letter DeadLetter
+		if err := decoder.Decode(&letter); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return errors.Join(append(errs, err)...)
+		}
+
+		if err := d.DispatchWebhook(ctx, letter.EventType, letter.Payload); err != nil {
+			errs = append(errs, fmt.Errorf("replay %s for %s: %w", letter.EventType, letter.ListenerType, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) deadLetter(ctx context.Context, eventType gitlab.EventType, listener, event any, err error) error {
+	letter := &DeadLetter{
+		EventType:    eventType,
+		ListenerType: fmt.Sprintf("%T", listener),
+		Error:        err.Error(),
+		FailedAt:     time.Now(),
+		Err:          err,
+	}
+	if dl, ok := DeliveryFromContext(ctx); ok && dl.isFor(event) {
+		letter.EventType, letter.Payload = dl.EventType, dl.Payload
+		letter.Header = dl.Header.Clone()
+	} else {
+		payload, marshalErr := json.Marshal(event)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		letter.Payload = payload
+	}
+
+	// a cancelled delivery must still reach the sink
+	return d.deadLetterSink.DeadLetter(context.WithoutCancel(ctx), letter)
+}
@@ -0,0 +1,110 @@
+package gitlabwebhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestDispatcher_WithDeadLetterSink(t *testing.T) {
+	var letters []*DeadLetter
+	sink := DeadLetterSinkFunc(func(_ context.Context, letter *DeadLetter) error {
+		letters = append(letters, letter)
+		return nil
+	})
+
+	dispatcher := NewDispatcher(
+		WithDeadLetterSink(sink),
+		RegisterListeners(failingPushListener{}, &simpleTestListener{}),
+	)
+
+	payload := loadFixture("testdata/webhooks/push.json")
+	req, err := http.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+	require.NoError(t, err)
+	req.Header.Set("X-Gitlab-Event", string(gitlab.EventTypePush))
+	req.Header.Set("X-Gitlab-Token", "secret")
+	req.Header.Set("X-Gitlab-Event-UUID", "uuid-1")
+
+	assert.Error(t, dispatcher.DispatchRequest(req, DispatchRequestWithToken("secret")))
+
+	require.Len(t, letters, 1)
+	letter := letters[0]
+	assert.Equal(t, gitlab.EventTypePush, letter.EventType)
+	assert.Equal(t, "gitlabwebhook.failingPushListener", letter.ListenerType)
+	assert.JSONEq(t, string(payload), string(letter.Payload))
+	assert.Equal(t, "uuid-1", letter.Header.Get("X-Gitlab-Event-UUID"))
+	assert.Empty(t, letter.Header.Get("X-Gitlab-Token"))
+	assert.Equal(t, "downstream unavailable", letter.Error)
+	assert.EqualError(t, letter.Err, "downstream unavailable")
+}
+
+func TestDispatcher_WithDeadLetterSinkDirectDispatch(t *testing.T) {
+	var letter *DeadLetter
+	dispatcher := NewDispatcher(
+		WithDeadLetterSink(DeadLetterSinkFunc(func(_ context.Context, l *DeadLetter) error {
+			letter = l
+			return nil
+		})),
+		RegisterListeners(failingPushListener{}),
+	)
+
+	assert.Error(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{Ref: "refs/heads/main"}))
+	require.NotNil(t, letter)
+	assert.Equal(t, gitlab.EventTypePush, letter.EventType)
+	assert.Contains(t, string(letter.Payload), `"ref":"refs/heads/main"`)
+}
+
+func TestDispatcher_WithDeadLetterSinkNestedDispatch(t *testing.T) {
+	var letters []*DeadLetter
+	dispatcher := NewDispatcher(WithDeadLetterSink(DeadLetterSinkFunc(func(_ context.Context, l *DeadLetter) error {
+		letters = append(letters, l)
+		return nil
+	})))
+	On(dispatcher, func(context.Context, *deployRequestedEvent) error {
+		return errors.New("deploy failed")
+	})
+	On(dispatcher, func(ctx context.Context, _ *gitlab.PushEvent) error {
+		return dispatcher.Dispatch(ctx, &deployRequestedEvent{Environment: "production"})
+	})
+
+	req := newPushRequest(t)
+	req.Header.Set("X-Gitlab-Event-UUID", "uuid-1")
+	require.Error(t, dispatcher.DispatchRequest(req))
+
+	// the failed deploy is recorded by itself, then the push it failed
+	require.Len(t, letters, 2)
+	deploy, push := letters[0], letters[1]
+	assert.Empty(t, deploy.EventType)
+	assert.JSONEq(t, `{"Environment":"production"}`, string(deploy.Payload))
+	assert.Nil(t, deploy.Header)
+	assert.Equal(t, gitlab.EventTypePush, push.EventType)
+	assert.JSONEq(t, string(loadFixture("testdata/webhooks/push.json")), string(push.Payload))
+	assert.Equal(t, "uuid-1", push.Header.Get("X-Gitlab-Event-UUID"))
+}
+
+func TestFileDeadLetterSink_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	failing := NewDispatcher(
+		WithDeadLetterSink(NewFileDeadLetterSink(path)),
+		RegisterListeners(failingPushListener{}),
+	)
+
+	ctx := context.Background()
+	assert.Error(t, failing.DispatchWebhook(ctx, gitlab.EventTypePush, loadFixture("testdata/webhooks/push.json")))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+
+	listener := &testListener{t: t}
+	replay := NewDispatcher(RegisterListeners(listener))
+	assert.NoError(t, replay.ReplayDeadLetters(newDispatcherContext(ctx), f))
+}
@@ -0,0 +1,124 @@
+package gitlabwebhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// DecodeFunc decodes the payload of a webhook delivery into an event.
+type DecodeFunc func(payload []byte) (any, error)
+
+type eventTypeOptions struct {
+	objectKind string
+}
+
+type EventTypeOption func(*eventTypeOptions)
+
+// EventTypeWithObjectKind only uses the decoder for payloads with the given
+// object_kind, for hooks that deliver several kinds of object.
+func EventTypeWithObjectKind(objectKind string) EventTypeOption {
+	return func(o *eventTypeOptions) {
+		o.objectKind = objectKind
+	}
+}
+
+type decoderKey struct {
+	eventType  gitlab.EventType
+	objectKind string
+}
+
+// RegisterEventType decodes deliveries of eventType with decode, in place of
+// the decoding built into the gitlab client. Register listeners for the type
+// decode returns with On. A decoder for an object_kind takes precedence over
+// one for the whole event type, and registering the same key again replaces
+// the previous decoder.
+//
+// Errors returned by decode are reported as ErrInvalidPayload unless they
+// wrap ErrUnsupportedEvent.
+func (d *Dispatcher) RegisterEventType(eventType gitlab.EventType, decode DecodeFunc, opts ...EventTypeOption) {
+	o := eventTypeOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	d.update(func(r *registry) {
+		if r.decoders == nil {
+			r.decoders = make(map[decoderKey]DecodeFunc)
+		}
+		r.decoders[decoderKey{eventType: eventType, objectKind: o.objectKind}] = decode
+		if o.objectKind != "" {
+			if r.objectKinds == nil {
+				r.objectKinds = make(map[gitlab.EventType]bool)
+			}
+			r.objectKinds[eventType] = true
+		}
+	})
+}
+
+// builtinDecoders decode the hooks the gitlab client does not decode, unless
+// a decoder is registered for them.
+var builtinDecoders = map[decoderKey]DecodeFunc{
+	{eventType: gitlab.EventTypeEmoji}:                                         decodeJSON[EmojiEvent],
+	{eventType: gitlab.EventTypeIssue, objectKind: objectKindWorkItem}:         decodeJSON[WorkItemEvent],
+	{eventType: gitlab.EventConfidentialIssue, objectKind: objectKindWorkItem}: decodeJSON[WorkItemEvent],
+}
+
+// builtinObjectKinds are the event types builtinDecoders has decoders for
+// an object_kind of.
+var builtinObjectKinds = map[gitlab.EventType]bool{
+	gitlab.EventTypeIssue:         true,
+	gitlab.EventConfidentialIssue: true,
+}
+
+// decoder returns the registered or built-in decoder for a delivery, if any.
+func (d *Dispatcher) decoder(eventType gitlab.EventType, payload []byte) (DecodeFunc, bool) {
+	r := d.loadRegistry()
+	if r.objectKinds[eventType] || builtinObjectKinds[eventType] {
+		key := decoderKey{eventType: eventType, objectKind: objectKind(payload)}
+		if decode, ok := r.decoders[key]; ok {
+			return decode, true
+		}
+		if decode, ok := builtinDecoders[key]; ok {
+			return decode, true
+		}
+	}
+	key := decoderKey{eventType: eventType}
+	if decode, ok := r.decoders[key]; ok {
+		return decode, true
+	}
+	decode, ok := builtinDecoders[key]
+	return decode, ok
+}
+
+func decodeWith(decode DecodeFunc, payload []byte) (any, error) {
+	event, err := decode(payload)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedEvent) || errors.Is(err, ErrInvalidPayload) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+	return event, nil
+}
+
+// decodeJSON decodes payload into a new E.
+func decodeJSON[E any](payload []byte) (any, error) {
+	event := new(E)
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func objectKind(payload []byte) string {
+	var p struct {
+		ObjectKind string `json:"object_kind"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return ""
+	}
+	return p.ObjectKind
+}
@@ -0,0 +1,85 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type auditEvent struct {
+	ObjectKind string `json:"object_kind"`
+	Action     string `json:"action"`
+}
+
+func TestDispatcher_RegisterEventType(t *testing.T) {
+	dispatcher := NewDispatcher()
+	dispatcher.RegisterEventType("Audit Hook", decodeJSON[auditEvent])
+
+	var actions []string
+	On(dispatcher, func(_ context.Context, event *auditEvent) error {
+		actions = append(actions, event.Action)
+		return nil
+	})
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, "Audit Hook", []byte(`{"object_kind":"audit","action":"login"}`)))
+	assert.ErrorIs(t, dispatcher.DispatchWebhook(ctx, "Audit Hook", []byte(`{"action":`)), ErrInvalidPayload)
+	assert.ErrorIs(t, dispatcher.DispatchWebhook(ctx, "Other Hook", []byte(`{}`)), ErrUnsupportedEvent)
+
+	assert.Equal(t, []string{"login"}, actions)
+}
+
+func TestDispatcher_RegisterEventTypeWithObjectKind(t *testing.T) {
+	issues := 0
+	dispatcher := NewDispatcher(RegisterListeners(IssueListenerFunc(func(context.Context, *gitlab.IssueEvent) error {
+		issues++
+		return nil
+	})))
+	dispatcher.RegisterEventType(gitlab.EventTypeIssue, func(payload []byte) (any, error) {
+		var event auditEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		if event.Action == "" {
+			return nil, errors.New("missing action")
+		}
+		return &event, nil
+	}, EventTypeWithObjectKind("audit"))
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, loadFixture("testdata/webhooks/issue.json")))
+	// no listeners for the decoded type is not an error
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, []byte(`{"object_kind":"audit","action":"login"}`)))   //nolint:lll
+	assert.ErrorIs(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, []byte(`{"object_kind":"audit"}`)), ErrInvalidPayload) //nolint:lll
+
+	assert.Equal(t, 1, issues)
+}
+
+func TestDispatcher_BuiltinDecoders(t *testing.T) {
+	var (
+		dispatcher Dispatcher
+		emojis     int
+		workItems  int
+	)
+	dispatcher.RegisterListeners(
+		EmojiListenerFunc(func(context.Context, *EmojiEvent) error {
+			emojis++
+			return nil
+		}),
+		WorkItemListenerFunc(func(context.Context, *WorkItemEvent) error {
+			workItems++
+			return nil
+		}),
+	)
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeEmoji, loadFixture("testdata/webhooks/emoji.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, loadFixture("testdata/webhooks/work_item.json")))
+
+	assert.Equal(t, 1, emojis)
+	assert.Equal(t, 1, workItems)
+}
@@ -0,0 +1,252 @@
+package gitlabwebhook
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupStore remembers which deliveries have been processed.
+type DedupStore interface {
+	// Claim records key and reports whether it is the first claim of key.
+	Claim(ctx context.Context, key string) (bool, error)
+	// Release forgets key, so that a redelivery of a failed event is
+	// processed again.
+	Release(ctx context.Context, key string) error
+}
+
+// DispatchRequestWithDedupStore skips deliveries whose Idempotency-Key, or
+// X-Gitlab-Event-UUID, has already been claimed in store: DispatchRequest
+// returns ErrDuplicateDelivery without calling any listener. Keys of
+// deliveries that fail are released again.
+func DispatchRequestWithDedupStore(store DedupStore) DispatchRequestOption {
+	return func(o *dispatchRequestOptions) {
+		o.dedupStore = store
+	}
+}
+
+func dedupKey(header http.Header) string {
+	if key := header.Get("Idempotency-Key"); key != "" {
+		return key
+	}
+	return header.Get("X-Gitlab-Event-UUID")
+}
+
+// releaseDelivery releases the dedup key of the delivery in ctx, if any.
+func releaseDelivery(ctx context.Context) error {
+	dl, ok := DeliveryFromContext(ctx)
+	if !ok || dl.dedupStore == nil {
+		return nil
+	}
+	return dl.dedupStore.Release(context.WithoutCancel(ctx), dl.dedupKey)
+}
+
+// MemoryDedupStore is an in-memory DedupStore that forgets keys after a TTL
+// and evicts the least recently claimed keys beyond its capacity.
+type MemoryDedupStore struct {
+	ttl      time.Duration
+	capacity int
+	now      func() time.Time
+
+	mu    sync.Mutex
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key     string
+	expires time.Time
+}
+
+func NewMemoryDedupStore(ttl time.Duration, capacity int) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		ttl:      ttl,
+		capacity: capacity,
+		now:      time.Now,
+		order:    list.New(),
+		keys:     make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryDedupStore) Claim(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if elem, ok := s.keys[key]; ok {
+		entry := elem.Value.(*dedupEntry)
+		if now.Before(entry.expires) {
+			return false, nil
+		}
+		entry.expires = now.Add(s.ttl)
+		s.order.MoveToFront(elem)
+		return true, nil
+	}
+
+	s.keys[key] = s.order.PushFront(&dedupEntry{key: key, expires: now.Add(s.ttl)})
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.keys, oldest.Value.(*dedupEntry).key)
+	}
+	return true, nil
+}
+
+func (s *MemoryDedupStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.keys[key]; ok {
+		s.order.Remove(elem)
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+// fileDedupCompactLines is the journal length from which FileDedupStore
+// compacts its journal, once it has doubled since the last compaction.
+const fileDedupCompactLines = 1024
+
+// FileDedupStore is a DedupStore that survives restarts by journaling claims
+// and releases to a file. The journal is compacted, and expired keys are
+// forgotten, when the store is opened and whenever the journal has doubled
+// since.
+type FileDedupStore struct {
+	path string
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]time.Time
+	// lines counts the lines of the journal, compacted the lines its last
+	// compaction kept.
+	lines, compacted int
+}
+
+// OpenFileDedupStore loads the journal at path, creating it if necessary.
+func OpenFileDedupStore(path string, ttl time.Duration) (*FileDedupStore, error) {
+	s := &FileDedupStore{
+		path: path,
+		ttl:  ttl,
+		now:  time.Now,
+		keys: make(map[string]time.Time),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, s.compact()
+}
+
+func (s *FileDedupStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		op, rest, _ := strings.Cut(scanner.Text(), " ")
+		switch op {
+		case "claim":
+			expires, key, ok := strings.Cut(rest, " ")
+			if !ok {
+				continue
+			}
+			unix, err := strconv.ParseInt(expires, 10, 64)
+			if err != nil {
+				continue
+			}
+			s.keys[key] = time.Unix(unix, 0)
+		case "release":
+			delete(s.keys, rest)
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *FileDedupStore) compact() error {
+	var b strings.Builder
+	now := s.now()
+	for key, expires := range s.keys {
+		if !now.Before(expires) {
+			delete(s.keys, key)
+			continue
+		}
+		fmt.Fprintf(&b, "claim %d %s\n", expires.Unix(), key)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.lines, s.compacted = len(s.keys), len(s.keys)
+	return nil
+}
+
+func (s *FileDedupStore) append(line string) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.lines++
+	return f.Close()
+}
+
+// maybeCompact compacts a journal that has grown stale. A failed compaction
+// leaves the journal intact and is tried again with the next line.
+func (s *FileDedupStore) maybeCompact() {
+	if s.lines >= max(fileDedupCompactLines, 2*s.compacted) {
+		_ = s.compact()
+	}
+}
+
+func (s *FileDedupStore) Claim(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if expires, ok := s.keys[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+
+	expires := now.Add(s.ttl)
+	if err := s.append(fmt.Sprintf("claim %d %s", expires.Unix(), key)); err != nil {
+		return false, err
+	}
+	s.keys[key] = expires
+	s.maybeCompact()
+	return true, nil
+}
+
+func (s *FileDedupStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key]; !ok {
+		return nil
+	}
+	if err := s.append("release " + key); err != nil {
+		return err
+	}
+	delete(s.keys, key)
+	s.maybeCompact()
+	return nil
+}
@@ -0,0 +1,139 @@
+package gitlabwebhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatcher_DispatchRequestWithDedupStore(t *testing.T) {
+	store := NewMemoryDedupStore(time.Hour, 100)
+	listener := &countingPushListener{}
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+
+	newRequest := func(header, key string) *http.Request {
+		req := newPushRequest(t)
+		req.Header.Set(header, key)
+		return req
+	}
+
+	assert.NoError(t, dispatcher.DispatchRequest(newRequest("X-Gitlab-Event-UUID", "a"), DispatchRequestWithDedupStore(store)))
+	assert.ErrorIs(t,
+		dispatcher.DispatchRequest(newRequest("X-Gitlab-Event-UUID", "a"), DispatchRequestWithDedupStore(store)),
+		ErrDuplicateDelivery,
+	)
+	assert.NoError(t, dispatcher.DispatchRequest(newRequest("Idempotency-Key", "b"), DispatchRequestWithDedupStore(store)))
+	assert.ErrorIs(t,
+		dispatcher.DispatchRequest(newRequest("Idempotency-Key", "b"), DispatchRequestWithDedupStore(store)),
+		ErrDuplicateDelivery,
+	)
+	assert.NoError(t, dispatcher.DispatchRequest(newPushRequest(t), DispatchRequestWithDedupStore(store)))
+	assert.NoError(t, dispatcher.DispatchRequest(newPushRequest(t), DispatchRequestWithDedupStore(store)))
+	assert.Equal(t, int64(4), listener.calls.Load())
+
+	rec := httptest.NewRecorder()
+	dispatcher.Handler(HandlerWithDispatchRequestOptions(DispatchRequestWithDedupStore(store))).
+		ServeHTTP(rec, newRequest("X-Gitlab-Event-UUID", "a"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(4), listener.calls.Load())
+}
+
+func TestDispatcher_DedupReleasesFailedDeliveries(t *testing.T) {
+	store := NewMemoryDedupStore(time.Hour, 100)
+	listener := &flakyPushListener{failures: 1}
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+
+	for _, wantErr := range []bool{true, false} {
+		req := newPushRequest(t)
+		req.Header.Set("X-Gitlab-Event-UUID", "a")
+		err := dispatcher.DispatchRequest(req, DispatchRequestWithDedupStore(store))
+		assert.Equal(t, wantErr, err != nil)
+	}
+	assert.Equal(t, int64(2), listener.calls.Load())
+}
+
+func TestMemoryDedupStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewMemoryDedupStore(time.Minute, 2)
+	store.now = func() time.Time { return now }
+
+	claim := func(key string) bool {
+		first, err := store.Claim(ctx, key)
+		require.NoError(t, err)
+		return first
+	}
+
+	assert.True(t, claim("a"))
+	assert.False(t, claim("a"))
+	assert.True(t, claim("b"))
+	assert.True(t, claim("c"))
+	assert.True(t, claim("a"), "least recently claimed key is evicted")
+
+	now = now.Add(2 * time.Minute)
+	assert.True(t, claim("c"), "expired key is claimable again")
+
+	require.NoError(t, store.Release(ctx, "c"))
+	assert.True(t, claim("c"))
+}
+
+func TestFileDedupStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dedup.log")
+
+	store, err := OpenFileDedupStore(path, time.Hour)
+	require.NoError(t, err)
+	for _, key := range []string{"a", "b"} {
+		first, err := store.Claim(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, first)
+	}
+	require.NoError(t, store.Release(ctx, "b"))
+
+	reopened, err := OpenFileDedupStore(path, time.Hour)
+	require.NoError(t, err)
+
+	first, err := reopened.Claim(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, first)
+
+	first, err = reopened.Claim(ctx, "b")
+	require.NoError(t, err)
+	assert.True(t, first)
+}
+
+func TestFileDedupStore_Compaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dedup.log")
+
+	now := time.Now()
+	store, err := OpenFileDedupStore(path, time.Minute)
+	require.NoError(t, err)
+	store.now = func() time.Time { return now }
+
+	for i := range 3 * fileDedupCompactLines {
+		now = now.Add(time.Second)
+		first, err := store.Claim(ctx, fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+		assert.True(t, first)
+	}
+
+	// only keys claimed within the TTL are kept, in memory and in the journal
+	assert.LessOrEqual(t, len(store.keys), fileDedupCompactLines)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, bytes.Count(content, []byte("\n")), 2*fileDedupCompactLines)
+
+	first, err := store.Claim(ctx, fmt.Sprintf("key-%d", 3*fileDedupCompactLines-1))
+	require.NoError(t, err)
+	assert.False(t, first)
+}
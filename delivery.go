@@ -0,0 +1,83 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type deliveryContextKey struct{}
+
+// Delivery describes the raw webhook an event was decoded from. It is
+// attached to the context passed to listeners by DispatchRequest and
+// DispatchWebhook.
+type Delivery struct {
+	EventType gitlab.EventType
+	// WebhookUUID is the X-Gitlab-Webhook-UUID header, unique per hook.
+	WebhookUUID string
+	// EventUUID is the X-Gitlab-Event-UUID header, shared by all hooks
+	// receiving the same event.
+	EventUUID string
+	// Instance is the X-Gitlab-Instance header.
+	Instance string
+	// Header holds the request headers, without the X-Gitlab-Token and
+	// Authorization secrets. It is nil for DispatchWebhook.
+	Header http.Header
+	// Payload is the raw JSON body, including fields the decoded event drops.
+	Payload    []byte
+	ReceivedAt time.Time
+
+	dedupStore DedupStore
+	dedupKey   string
+	// event is the event decoded from Payload.
+	event any
+}
+
+// DeliveryFromContext returns the Delivery attached to ctx, if any. Events
+// passed to Dispatch directly have no Delivery.
+func DeliveryFromContext(ctx context.Context) (*Delivery, bool) {
+	d, ok := ctx.Value(deliveryContextKey{}).(*Delivery)
+	return d, ok && d != nil
+}
+
+// isFor reports whether event was decoded from the delivery, rather than
+// dispatched by a listener with the context it received. Decoded events are
+// compared by reference, as decoders return pointers or raw JSON.
+func (d *Delivery) isFor(event any) bool {
+	a, b := reflect.ValueOf(d.event), reflect.ValueOf(event)
+	if !a.IsValid() || !b.IsValid() || a.Type() != b.Type() {
+		return false
+	}
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Map:
+		return a.Pointer() == b.Pointer()
+	case reflect.Slice:
+		return a.Pointer() == b.Pointer() && a.Len() == b.Len()
+	default:
+		return false
+	}
+}
+
+func newRequestDelivery(req *http.Request, payload []byte, receivedAt time.Time) *Delivery {
+	// secrets must not reach listeners, middleware or the dead letter sink
+	header := req.Header.Clone()
+	header.Del("X-Gitlab-Token")
+	header.Del("Authorization")
+
+	return &Delivery{
+		EventType:   gitlab.HookEventType(req),
+		WebhookUUID: req.Header.Get("X-Gitlab-Webhook-UUID"),
+		EventUUID:   req.Header.Get("X-Gitlab-Event-UUID"),
+		Instance:    req.Header.Get("X-Gitlab-Instance"),
+		Header:      header,
+		Payload:     payload,
+		ReceivedAt:  receivedAt,
+	}
+}
+
+func withDelivery(ctx context.Context, d *Delivery) context.Context {
+	return context.WithValue(ctx, deliveryContextKey{}, d)
+}
@@ -0,0 +1,165 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type deliveryPushListener struct {
+	delivery *Delivery
+	ok       bool
+}
+
+func (l *deliveryPushListener) OnPush(ctx context.Context, _ *gitlab.PushEvent) error {
+	l.delivery, l.ok = DeliveryFromContext(ctx)
+	return nil
+}
+
+func TestDeliveryFromContext(t *testing.T) {
+	payload := loadFixture("testdata/webhooks/push.json")
+
+	t.Run("request", func(t *testing.T) {
+		listener := &deliveryPushListener{}
+		dispatcher := NewDispatcher(RegisterListeners(listener))
+
+		req := newPushRequest(t)
+		req.Header.Set("X-Gitlab-Webhook-UUID", "webhook-uuid")
+		req.Header.Set("X-Gitlab-Event-UUID", "event-uuid")
+		req.Header.Set("X-Gitlab-Instance", "https://gitlab.example.com")
+		req.Header.Set("X-Gitlab-Token", "s3cret")
+		req.Header.Set("Authorization", "Bearer s3cret")
+
+		before := time.Now()
+		require.NoError(t, dispatcher.DispatchRequest(req, DispatchRequestWithToken("s3cret")))
+
+		require.True(t, listener.ok)
+		dl := listener.delivery
+		assert.Equal(t, gitlab.EventTypePush, dl.EventType)
+		assert.Equal(t, "webhook-uuid", dl.WebhookUUID)
+		assert.Equal(t, "event-uuid", dl.EventUUID)
+		assert.Equal(t, "https://gitlab.example.com", dl.Instance)
+		assert.Equal(t, "event-uuid", dl.Header.Get("X-Gitlab-Event-UUID"))
+		assert.NotContains(t, dl.Header, "X-Gitlab-Token")
+		assert.NotContains(t, dl.Header, "Authorization")
+		assert.Equal(t, "s3cret", req.Header.Get("X-Gitlab-Token"))
+		assert.Equal(t, payload, dl.Payload)
+		assert.False(t, dl.ReceivedAt.Before(before))
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		listener := &deliveryPushListener{}
+		dispatcher := NewDispatcher(RegisterListeners(listener))
+
+		require.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypePush, payload))
+
+		require.True(t, listener.ok)
+		assert.Equal(t, gitlab.EventTypePush, listener.delivery.EventType)
+		assert.Equal(t, payload, listener.delivery.Payload)
+		assert.Nil(t, listener.delivery.Header)
+	})
+
+	t.Run("event", func(t *testing.T) {
+		listener := &deliveryPushListener{}
+		dispatcher := NewDispatcher(RegisterListeners(listener))
+
+		require.NoError(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{}))
+		assert.False(t, listener.ok)
+	})
+}
+
+func TestDeliveryFromContext_NestedDispatch(t *testing.T) {
+	dispatcher := NewDispatcher()
+
+	var (
+		mu         sync.Mutex
+		eventTypes []gitlab.EventType
+		nestedErr  error
+		nested     bool
+	)
+	dispatcher.RegisterAnyListener(AnyListenerFunc(func(_ context.Context, eventType gitlab.EventType, _ any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		eventTypes = append(eventTypes, eventType)
+		return nil
+	}))
+	On(dispatcher, func(ctx context.Context, _ *deployRequestedEvent) error {
+		_, nested = DeliveryFromContext(ctx)
+		return nil
+	})
+	On(dispatcher, func(ctx context.Context, _ *gitlab.PushEvent) error {
+		nestedErr = dispatcher.Dispatch(ctx, &deployRequestedEvent{Environment: "production"})
+		return nil
+	})
+
+	payload := loadFixture("testdata/webhooks/push.json")
+	require.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypePush, payload))
+	require.NoError(t, nestedErr)
+
+	// the nested event is not attributed to the push delivery
+	assert.ElementsMatch(t, []gitlab.EventType{gitlab.EventTypePush, ""}, eventTypes)
+	assert.False(t, nested)
+}
+
+func TestDeliveryFromContext_NestedConfidential(t *testing.T) {
+	dispatcher := NewDispatcher()
+
+	var (
+		mu           sync.Mutex
+		pushes       []bool
+		issues       []bool
+		confidential int
+		nestedErr    error
+	)
+	dispatcher.RegisterListeners(ConfidentialIssueListenerFunc(func(ctx context.Context, _ *gitlab.IssueEvent) error {
+		mu.Lock()
+		confidential++
+		mu.Unlock()
+		nestedErr = errors.Join(
+			dispatcher.Dispatch(ctx, &gitlab.PushEvent{}),
+			dispatcher.Dispatch(ctx, &gitlab.IssueEvent{}),
+		)
+		return nil
+	}))
+	On(dispatcher, func(ctx context.Context, _ *gitlab.PushEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		pushes = append(pushes, IsConfidential(ctx))
+		return nil
+	})
+	dispatcher.RegisterIssueListener(IssueListenerFunc(func(ctx context.Context, _ *gitlab.IssueEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		issues = append(issues, IsConfidential(ctx))
+		return nil
+	}))
+
+	payload := loadFixture("testdata/webhooks/confidential_issue.json")
+	require.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventConfidentialIssue, payload))
+	require.NoError(t, nestedErr)
+
+	// the nested events are not taken for confidential ones
+	assert.Equal(t, 1, confidential)
+	assert.Equal(t, []bool{false}, pushes)
+	assert.ElementsMatch(t, []bool{true, false}, issues)
+}
+
+func TestDeliveryFromContext_NestedUnsupported(t *testing.T) {
+	type unregisteredEvent struct{}
+
+	var nestedErr error
+	dispatcher := NewDispatcher()
+	On(dispatcher, func(ctx context.Context, _ *gitlab.PushEvent) error {
+		nestedErr = dispatcher.Dispatch(ctx, &unregisteredEvent{})
+		return nil
+	})
+
+	require.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypePush, loadFixture("testdata/webhooks/push.json")))
+	assert.ErrorIs(t, nestedErr, ErrUnsupportedEvent)
+}
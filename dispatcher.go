@@ -2,42 +2,50 @@ package gitlabwebhook
 
 import (
 	"context"
-	"crypto/subtle"
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go"
 )
 
 var (
-	ErrUnsupportedEvent = errors.New("gitlab-webhook: unsupported event type")
-	ErrInvalidToken     = errors.New("gitlab-webhook: invalid token")
+	ErrUnsupportedEvent  = errors.New("gitlab-webhook: unsupported event type")
+	ErrInvalidToken      = errors.New("gitlab-webhook: invalid token")
+	ErrInvalidPayload    = errors.New("gitlab-webhook: invalid payload")
+	ErrMethodNotAllowed  = errors.New("gitlab-webhook: method not allowed")
+	ErrInvalidSignature  = errors.New("gitlab-webhook: invalid signature")
+	ErrStaleDelivery     = errors.New("gitlab-webhook: stale delivery")
+	ErrQueueFull         = errors.New("gitlab-webhook: queue full")
+	ErrDispatcherClosed  = errors.New("gitlab-webhook: dispatcher closed")
+	ErrDuplicateDelivery = errors.New("gitlab-webhook: duplicate delivery")
+	ErrListenerTimeout   = errors.New("gitlab-webhook: listener timeout")
 )
 
 type Dispatcher struct {
-	buildListeners                      []BuildListener
-	commitCommentListeners              []CommitCommentListener
-	deploymentListeners                 []DeploymentListener
-	emojiListeners                      []EmojiListener
-	featureFlagListeners                []FeatureFlagListener
-	groupResourceAccessTokenListeners   []GroupResourceAccessTokenListener
-	issueCommentListeners               []IssueCommentListener
-	issueListeners                      []IssueListener
-	jobListeners                        []JobListener
-	memberListeners                     []MemberListener
-	mergeCommentListeners               []MergeCommentListener
-	mergeListeners                      []MergeListener
-	pipelineListeners                   []PipelineListener
-	projectResourceAccessTokenListeners []ProjectResourceAccessTokenListener
-	pushListeners                       []PushListener
-	releaseListeners                    []ReleaseListener
-	snippetCommentListeners             []SnippetCommentListener
-	subGroupListeners                   []SubGroupListener
-	tagListeners                        []TagListener
-	wikiPageListeners                   []WikiPageListener
+	mu       sync.Mutex // serializes registry updates
+	registry atomic.Pointer[registry]
+	nextID   uint64
+
+	pool           *workerPool
+	retryPolicy    RetryPolicy
+	deadLetterSink DeadLetterSink
+
+	listenerTimeout   time.Duration
+	eventTypeTimeouts map[gitlab.EventType]time.Duration
+
+	confidentialListenersOnly bool
+
+	queueOnce         sync.Once
+	queue             *asyncQueue
+	queueSize         int
+	queueWorkers      int
+	asyncErrorHandler AsyncErrorHandler
 }
 
 type Option func(*Dispatcher)
@@ -48,6 +56,16 @@ func RegisterListeners(listeners ...any) Option {
 	}
 }
 
+// WithConcurrency runs listeners on a pool of n workers shared by all
+// deliveries instead of starting a goroutine per listener per event.
+func WithConcurrency(n int) Option {
+	return func(d *Dispatcher) {
+		if n > 0 {
+			d.pool = newWorkerPool(n)
+		}
+	}
+}
+
 func NewDispatcher(opts ...Option) *Dispatcher {
 	dispatcher := &Dispatcher{}
 	for _, opt := range opts {
@@ -56,383 +74,519 @@ func NewDispatcher(opts ...Option) *Dispatcher {
 	return dispatcher
 }
 
-func (d *Dispatcher) RegisterListeners(listeners ...any) {
+// RegisterListeners registers each listener for every listener interface it
+// implements. ListenerOption values among listeners apply to all of them.
+func (d *Dispatcher) RegisterListeners(listeners ...any) Unsubscribe {
+	var opts []ListenerOption
 	for _, listener := range listeners {
-		if l, ok := listener.(BuildListener); ok {
-			d.RegisterBuildListener(l)
+		if opt, ok := listener.(ListenerOption); ok {
+			opts = append(opts, opt)
 		}
+	}
+	o := newListenerOptions(opts)
 
-		if l, ok := listener.(CommitCommentListener); ok {
-			d.RegisterCommitCommentListener(l)
+	var subs []subscription
+	for _, listener := range listeners {
+		if _, ok := listener.(ListenerOption); ok {
+			continue
 		}
-
-		if l, ok := listener.(DeploymentListener); ok {
-			d.RegisterDeploymentListener(l)
+		for _, bind := range listenerBindings {
+			if sub, ok := bind(listener, o); ok {
+				subs = append(subs, sub)
+			}
 		}
+	}
+	return d.subscribe(subs...)
+}
 
-		if l, ok := listener.(EmojiListener); ok {
-			d.RegisterEmojiListener(l)
-		}
+func (d *Dispatcher) RegisterAccessRequestSystemListener(listener AccessRequestSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, AccessRequestSystemListener.OnAccessRequestSystem, listener, opts...)
+}
 
-		if l, ok := listener.(FeatureFlagListener); ok {
-			d.RegisterFeatureFlagListener(l)
-		}
+func (d *Dispatcher) RegisterAnyListener(listener AnyListener, opts ...ListenerOption) Unsubscribe {
+	return d.subscribe(anySubscription(listener, newListenerOptions(opts)))
+}
 
-		if l, ok := listener.(GroupResourceAccessTokenListener); ok {
-			d.RegisterGroupResourceAccessTokenListener(l)
-		}
+func (d *Dispatcher) RegisterBuildListener(listener BuildListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, BuildListener.OnBuild, listener, opts...)
+}
 
-		if l, ok := listener.(IssueCommentListener); ok {
-			d.RegisterIssueCommentListener(l)
-		}
+func (d *Dispatcher) RegisterCommitCommentListener(listener CommitCommentListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, CommitCommentListener.OnCommitComment, listener, opts...)
+}
 
-		if l, ok := listener.(IssueListener); ok {
-			d.RegisterIssueListener(l)
-		}
+func (d *Dispatcher) RegisterConfidentialIssueListener(listener ConfidentialIssueListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantConfidential, ConfidentialIssueListener.OnConfidentialIssue, listener, opts...)
+}
 
-		if l, ok := listener.(JobListener); ok {
-			d.RegisterJobListener(l)
-		}
+func (d *Dispatcher) RegisterConfidentialNoteListener(listener ConfidentialNoteListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantConfidential, ConfidentialNoteListener.OnConfidentialNote, listener, opts...)
+}
 
-		if l, ok := listener.(MemberListener); ok {
-			d.RegisterMemberListener(l)
-		}
+func (d *Dispatcher) RegisterDeploymentListener(listener DeploymentListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, DeploymentListener.OnDeployment, listener, opts...)
+}
 
-		if l, ok := listener.(MergeCommentListener); ok {
-			d.RegisterMergeCommentListener(l)
-		}
+func (d *Dispatcher) RegisterEmojiListener(listener EmojiListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, EmojiListener.OnEmoji, listener, opts...)
+}
 
-		if l, ok := listener.(MergeListener); ok {
-			d.RegisterMergeListener(l)
-		}
+func (d *Dispatcher) RegisterFeatureFlagListener(listener FeatureFlagListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, FeatureFlagListener.OnFeatureFlag, listener, opts...)
+}
 
-		if l, ok := listener.(PipelineListener); ok {
-			d.RegisterPipelineListener(l)
-		}
+func (d *Dispatcher) RegisterGroupResourceAccessTokenListener(listener GroupResourceAccessTokenListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, GroupResourceAccessTokenListener.OnGroupResourceAccessToken, listener, opts...)
+}
 
-		if l, ok := listener.(ProjectResourceAccessTokenListener); ok {
-			d.RegisterProjectResourceAccessTokenListener(l)
-		}
+func (d *Dispatcher) RegisterGroupSystemListener(listener GroupSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, GroupSystemListener.OnGroupSystem, listener, opts...)
+}
 
-		if l, ok := listener.(PushListener); ok {
-			d.RegisterPushListener(l)
-		}
+func (d *Dispatcher) RegisterIssueCommentListener(listener IssueCommentListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, IssueCommentListener.OnIssueComment, listener, opts...)
+}
 
-		if l, ok := listener.(ReleaseListener); ok {
-			d.RegisterReleaseListener(l)
-		}
+func (d *Dispatcher) RegisterIssueListener(listener IssueListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, IssueListener.OnIssue, listener, opts...)
+}
 
-		if l, ok := listener.(SnippetCommentListener); ok {
-			d.RegisterSnippetCommentListener(l)
-		}
+func (d *Dispatcher) RegisterJobListener(listener JobListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, JobListener.OnJob, listener, opts...)
+}
 
-		if l, ok := listener.(SubGroupListener); ok {
-			d.RegisterSubGroupListener(l)
-		}
+func (d *Dispatcher) RegisterKeySystemListener(listener KeySystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, KeySystemListener.OnKeySystem, listener, opts...)
+}
 
-		if l, ok := listener.(TagListener); ok {
-			d.RegisterTagListener(l)
-		}
+func (d *Dispatcher) RegisterMemberAccessRequestListener(listener MemberAccessRequestListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantAccessRequest, MemberAccessRequestListener.OnMemberAccessRequest, listener, opts...)
+}
 
-		if l, ok := listener.(WikiPageListener); ok {
-			d.RegisterWikiPageListener(l)
-		}
-	}
+func (d *Dispatcher) RegisterMemberListener(listener MemberListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, MemberListener.OnMember, listener, opts...)
+}
+
+func (d *Dispatcher) RegisterMergeCommentListener(listener MergeCommentListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, MergeCommentListener.OnMergeComment, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterBuildListener(listeners ...BuildListener) {
-	d.buildListeners = append(d.buildListeners, listeners...)
+func (d *Dispatcher) RegisterMergeListener(listener MergeListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, MergeListener.OnMerge, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterCommitCommentListener(listeners ...CommitCommentListener) {
-	d.commitCommentListeners = append(d.commitCommentListeners, listeners...)
+func (d *Dispatcher) RegisterMilestoneListener(listener MilestoneListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, MilestoneListener.OnMilestone, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterDeploymentListener(listeners ...DeploymentListener) {
-	d.deploymentListeners = append(d.deploymentListeners, listeners...)
+func (d *Dispatcher) RegisterPipelineListener(listener PipelineListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, PipelineListener.OnPipeline, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterEmojiListener(listeners ...EmojiListener) {
-	d.emojiListeners = append(d.emojiListeners, listeners...)
+func (d *Dispatcher) RegisterProjectListener(listener ProjectListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, ProjectListener.OnProject, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterFeatureFlagListener(listeners ...FeatureFlagListener) {
-	d.featureFlagListeners = append(d.featureFlagListeners, listeners...)
+func (d *Dispatcher) RegisterProjectResourceAccessTokenListener(listener ProjectResourceAccessTokenListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, ProjectResourceAccessTokenListener.OnProjectResourceAccessToken, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterGroupResourceAccessTokenListener(listeners ...GroupResourceAccessTokenListener) {
-	d.groupResourceAccessTokenListeners = append(d.groupResourceAccessTokenListeners, listeners...)
+func (d *Dispatcher) RegisterProjectSystemListener(listener ProjectSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, ProjectSystemListener.OnProjectSystem, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterIssueCommentListener(listeners ...IssueCommentListener) {
-	d.issueCommentListeners = append(d.issueCommentListeners, listeners...)
+func (d *Dispatcher) RegisterPushListener(listener PushListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, PushListener.OnPush, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterIssueListener(listeners ...IssueListener) {
-	d.issueListeners = append(d.issueListeners, listeners...)
+func (d *Dispatcher) RegisterPushSystemListener(listener PushSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, PushSystemListener.OnPushSystem, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterJobListener(listeners ...JobListener) {
-	d.jobListeners = append(d.jobListeners, listeners...)
+func (d *Dispatcher) RegisterReleaseListener(listener ReleaseListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, ReleaseListener.OnRelease, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterMemberListener(listeners ...MemberListener) {
-	d.memberListeners = append(d.memberListeners, listeners...)
+func (d *Dispatcher) RegisterRepositoryUpdateSystemListener(listener RepositoryUpdateSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, RepositoryUpdateSystemListener.OnRepositoryUpdateSystem, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterMergeCommentListener(listeners ...MergeCommentListener) {
-	d.mergeCommentListeners = append(d.mergeCommentListeners, listeners...)
+func (d *Dispatcher) RegisterSnippetCommentListener(listener SnippetCommentListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, SnippetCommentListener.OnSnippetComment, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterMergeListener(listeners ...MergeListener) {
-	d.mergeListeners = append(d.mergeListeners, listeners...)
+func (d *Dispatcher) RegisterSubGroupListener(listener SubGroupListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, SubGroupListener.OnSubGroup, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterPipelineListener(listeners ...PipelineListener) {
-	d.pipelineListeners = append(d.pipelineListeners, listeners...)
+func (d *Dispatcher) RegisterTagListener(listener TagListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, TagListener.OnTag, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterProjectResourceAccessTokenListener(listeners ...ProjectResourceAccessTokenListener) {
-	d.projectResourceAccessTokenListeners = append(d.projectResourceAccessTokenListeners, listeners...)
+func (d *Dispatcher) RegisterTagPushSystemListener(listener TagPushSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, TagPushSystemListener.OnTagPushSystem, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterPushListener(listeners ...PushListener) {
-	d.pushListeners = append(d.pushListeners, listeners...)
+func (d *Dispatcher) RegisterUserGroupSystemListener(listener UserGroupSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, UserGroupSystemListener.OnUserGroupSystem, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterReleaseListener(listeners ...ReleaseListener) {
-	d.releaseListeners = append(d.releaseListeners, listeners...)
+func (d *Dispatcher) RegisterUserSystemListener(listener UserSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, UserSystemListener.OnUserSystem, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterSnippetCommentListener(listeners ...SnippetCommentListener) {
-	d.snippetCommentListeners = append(d.snippetCommentListeners, listeners...)
+func (d *Dispatcher) RegisterUserTeamSystemListener(listener UserTeamSystemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, UserTeamSystemListener.OnUserTeamSystem, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterSubGroupListener(listeners ...SubGroupListener) {
-	d.subGroupListeners = append(d.subGroupListeners, listeners...)
+func (d *Dispatcher) RegisterVulnerabilityListener(listener VulnerabilityListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, VulnerabilityListener.OnVulnerability, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterTagListener(listeners ...TagListener) {
-	d.tagListeners = append(d.tagListeners, listeners...)
+func (d *Dispatcher) RegisterWikiPageListener(listener WikiPageListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, WikiPageListener.OnWikiPage, listener, opts...)
 }
 
-func (d *Dispatcher) RegisterWikiPageListener(listeners ...WikiPageListener) {
-	d.wikiPageListeners = append(d.wikiPageListeners, listeners...)
+func (d *Dispatcher) RegisterWorkItemListener(listener WorkItemListener, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, WorkItemListener.OnWorkItem, listener, opts...)
 }
 
 func (d *Dispatcher) Dispatch(ctx context.Context, event any) error {
-	switch e := event.(type) {
+	return d.dispatch(ctx, event, nil)
+}
+
+// dispatch passes event to its listeners, recording their outcome in report
+// unless it is nil.
+func (d *Dispatcher) dispatch(ctx context.Context, event any, report *DispatchReport) error {
+	eventType := eventTypeOf(event)
+	dl, hasDelivery := DeliveryFromContext(ctx)
+	switch {
+	case hasDelivery && dl.isFor(event):
+		if isConfidentialEventType(dl.EventType) {
+			ctx = withConfidential(ctx)
+		} else {
+			ctx = withoutConfidential(ctx)
+		}
+		eventType = dl.EventType
+	case hasDelivery:
+		// dispatched by a listener with the context of another delivery
+		ctx, hasDelivery = withoutConfidential(withDelivery(ctx, nil)), false
+	default:
+		// confidentiality of another event dispatched with ctx
+		ctx = withoutConfidential(ctx)
+	}
+	if report != nil {
+		report.EventType = eventType
+		if hasDelivery {
+			report.DeliveryID = dedupKey(dl.Header)
+		}
+	}
+
+	ctx, listeners, ok := d.listenersFor(ctx, eventType, event)
+	if !ok {
+		report.setStatus(DispatchStatusUnsupported)
+		return ErrUnsupportedEvent
+	}
+	return processEvent(ctx, d, eventType, listeners, event, report)
+}
+
+// eventTypeOf returns the webhook event type a GitLab event is delivered
+// with, or "" for any other type.
+func eventTypeOf(event any) gitlab.EventType {
+	switch event.(type) {
 	case *gitlab.BuildEvent:
-		return d.processBuildEvent(ctx, e)
-	case *gitlab.CommitCommentEvent:
-		return d.processCommitCommentEvent(ctx, e)
+		return gitlab.EventTypeBuild
 	case *gitlab.DeploymentEvent:
-		return d.processDeploymentEvent(ctx, e)
+		return gitlab.EventTypeDeployment
 	case *EmojiEvent:
-		return d.processEmojiEvent(ctx, e)
+		return gitlab.EventTypeEmoji
 	case *gitlab.FeatureFlagEvent:
-		return d.processFeatureFlagEvent(ctx, e)
-	case *gitlab.GroupResourceAccessTokenEvent:
-		return d.processGroupResourceAccessTokenEvent(ctx, e)
-	case *gitlab.IssueCommentEvent:
-		return d.processIssueCommentEvent(ctx, e)
-	case *gitlab.IssueEvent:
-		return d.processIssueEvent(ctx, e)
+		return gitlab.EventTypeFeatureFlag
+	case *gitlab.IssueEvent, *WorkItemEvent:
+		return gitlab.EventTypeIssue
 	case *gitlab.JobEvent:
-		return d.processJobEvent(ctx, e)
+		return gitlab.EventTypeJob
 	case *gitlab.MemberEvent:
-		return d.processMemberEvent(ctx, e)
-	case *gitlab.MergeCommentEvent:
-		return d.processMergeCommentEvent(ctx, e)
+		return gitlab.EventTypeMember
 	case *gitlab.MergeEvent:
-		return d.processMergeEvent(ctx, e)
+		return gitlab.EventTypeMergeRequest
+	case *gitlab.MilestoneWebhookEvent:
+		return gitlab.EventTypeMilestone
+	case *gitlab.CommitCommentEvent, *gitlab.IssueCommentEvent, *gitlab.MergeCommentEvent, *gitlab.SnippetCommentEvent:
+		return gitlab.EventTypeNote
 	case *gitlab.PipelineEvent:
-		return d.processPipelineEvent(ctx, e)
-	case *gitlab.ProjectResourceAccessTokenEvent:
-		return d.processProjectResourceAccessTokenEvent(ctx, e)
+		return gitlab.EventTypePipeline
+	case *gitlab.ProjectWebhookEvent:
+		return gitlab.EventTypeProject
 	case *gitlab.PushEvent:
-		return d.processPushEvent(ctx, e)
+		return gitlab.EventTypePush
 	case *gitlab.ReleaseEvent:
-		return d.processReleaseEvent(ctx, e)
-	case *gitlab.SnippetCommentEvent:
-		return d.processSnippetCommentEvent(ctx, e)
+		return gitlab.EventTypeRelease
+	case *gitlab.GroupResourceAccessTokenEvent, *gitlab.ProjectResourceAccessTokenEvent:
+		return gitlab.EventTypeResourceAccessToken
 	case *gitlab.SubGroupEvent:
-		return d.processSubGroupEvent(ctx, e)
+		return gitlab.EventTypeSubGroup
 	case *gitlab.TagEvent:
-		return d.processTagEvent(ctx, e)
+		return gitlab.EventTypeTagPush
+	case *gitlab.VulnerabilityEvent:
+		return gitlab.EventTypeVulnerability
 	case *gitlab.WikiPageEvent:
-		return d.processWikiPageEvent(ctx, e)
+		return gitlab.EventTypeWikiPage
+	case *AccessRequestSystemEvent, *gitlab.GroupSystemEvent, *gitlab.KeySystemEvent, *gitlab.ProjectSystemEvent,
+		*gitlab.PushSystemEvent, *gitlab.RepositoryUpdateSystemEvent, *gitlab.TagPushSystemEvent,
+		*gitlab.UserGroupSystemEvent, *gitlab.UserSystemEvent, *gitlab.UserTeamSystemEvent:
+		return gitlab.EventTypeSystemHook
 	default:
-		return ErrUnsupportedEvent
+		return ""
 	}
 }
 
 func (d *Dispatcher) DispatchWebhook(ctx context.Context, eventType gitlab.EventType, payload []byte) error {
-	// Handle emoji events specially since they're not in the gitlab library
-	if eventType == "Emoji Hook" {
-		var event EmojiEvent
-		if err := json.Unmarshal(payload, &event); err != nil {
-			return err
-		}
-		return d.Dispatch(ctx, &event)
+	if d.skipDecoding(eventType) {
+		return nil
 	}
-
-	event, err := gitlab.ParseWebhook(eventType, payload)
+	event, err := d.decodeWebhook(eventType, payload)
 	if err != nil {
 		return err
 	}
+	ctx = withDelivery(ctx, &Delivery{EventType: eventType, Payload: payload, ReceivedAt: time.Now(), event: event})
 	return d.Dispatch(ctx, event)
 }
 
-type dispatchRequestOptions struct {
-	ctx   context.Context
-	token string
-}
-
-type DispatchRequestOption func(*dispatchRequestOptions)
-
-func DispatchRequestWithContext(ctx context.Context) DispatchRequestOption {
-	return func(o *dispatchRequestOptions) {
-		o.ctx = ctx
+// decodeWebhook decodes a delivery with the decoder registered for it, or the
+// gitlab client otherwise. Unsupported events are passed on as raw JSON when
+// an AnyListener is registered to receive them.
+func (d *Dispatcher) decodeWebhook(eventType gitlab.EventType, payload []byte) (any, error) {
+	if decode, ok := d.decoder(eventType, payload); ok {
+		return decodeWith(decode, payload)
 	}
-}
 
-func DispatchRequestWithToken(token string) DispatchRequestOption {
-	return func(o *dispatchRequestOptions) {
-		o.token = token
+	event, err := parseWebhook(eventType, payload)
+	if errors.Is(err, ErrUnsupportedEvent) && len(d.loadRegistry().listeners[listenerKey{}]) > 0 {
+		return json.RawMessage(payload), nil
 	}
+	return event, err
 }
 
-func (d *Dispatcher) DispatchRequest(req *http.Request, opts ...DispatchRequestOption) error {
-	o := &dispatchRequestOptions{
-		ctx: req.Context(),
-	}
-	for _, opt := range opts {
-		opt(o)
-	}
-
-	// check token if provided
-	if o.token != "" {
-		token := req.Header.Get("X-Gitlab-Token")
-		// constant time compare to prevent timing attacks on token comparison
-		if subtle.ConstantTimeCompare([]byte(token), []byte(o.token)) != 1 {
-			return ErrInvalidToken
-		}
+func parseWebhook(eventType gitlab.EventType, payload []byte) (any, error) {
+	var event any
+	var err error
+	if eventType == gitlab.EventTypeSystemHook {
+		event, err = parseSystemHook(payload)
+	} else {
+		event, err = gitlab.ParseWebhook(eventType, payload)
 	}
-
-	// read payload
-	payload, err := io.ReadAll(req.Body)
 	if err != nil {
-		return err
+		if !clientEventTypes[eventType] || isUnsupportedKind(err) {
+			return nil, fmt.Errorf("%w: %w", ErrUnsupportedEvent, err)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPayload, err)
 	}
-
-	// dispatch webhook
-	return d.DispatchWebhook(o.ctx, gitlab.HookEventType(req), payload)
-}
-
-func (d *Dispatcher) processBuildEvent(ctx context.Context, event *gitlab.BuildEvent) error {
-	return processEvent(ctx, d.buildListeners, BuildListener.OnBuild, event)
-}
-
-func (d *Dispatcher) processCommitCommentEvent(ctx context.Context, event *gitlab.CommitCommentEvent) error {
-	return processEvent(ctx, d.commitCommentListeners, CommitCommentListener.OnCommitComment, event)
+	return event, nil
 }
 
-func (d *Dispatcher) processDeploymentEvent(ctx context.Context, event *gitlab.DeploymentEvent) error {
-	return processEvent(ctx, d.deploymentListeners, DeploymentListener.OnDeployment, event)
+// unsupportedKindErrors are the prefixes of the errors the gitlab client
+// returns for payloads of a kind it does not know, rather than malformed ones.
+var unsupportedKindErrors = []string{
+	"unexpected event type: ",
+	"unexpected object kind ",
+	"unexpected noteable type ",
+	"unexpected resource access token payload",
+	"unexpected service type ",
+	"unexpected system hook type ",
 }
 
-func (d *Dispatcher) processEmojiEvent(ctx context.Context, event *EmojiEvent) error {
-	return processEvent(ctx, d.emojiListeners, EmojiListener.OnEmoji, event)
-}
-
-func (d *Dispatcher) processFeatureFlagEvent(ctx context.Context, event *gitlab.FeatureFlagEvent) error {
-	return processEvent(ctx, d.featureFlagListeners, FeatureFlagListener.OnFeatureFlag, event)
+func isUnsupportedKind(err error) bool {
+	for _, prefix := range unsupportedKindErrors {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+	return false
 }
 
-func (d *Dispatcher) processGroupResourceAccessTokenEvent(ctx context.Context, event *gitlab.GroupResourceAccessTokenEvent) error { //nolint:lll
-	return processEvent(ctx, d.groupResourceAccessTokenListeners, GroupResourceAccessTokenListener.OnGroupResourceAccessToken, event)
+type dispatchRequestOptions struct {
+	ctx                context.Context
+	tokenStore         TokenStore
+	signingSecrets     [][]byte
+	signatureTolerance time.Duration
+	dedupStore         DedupStore
+	status             *DispatchStatus
+	report             *DispatchReport
 }
 
-func (d *Dispatcher) processIssueCommentEvent(ctx context.Context, event *gitlab.IssueCommentEvent) error {
-	return processEvent(ctx, d.issueCommentListeners, IssueCommentListener.OnIssueComment, event)
+func newDispatchRequestOptions(req *http.Request, opts ...DispatchRequestOption) *dispatchRequestOptions {
+	o := &dispatchRequestOptions{
+		ctx:                req.Context(),
+		signatureTolerance: DefaultSignatureTolerance,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
 }
 
-func (d *Dispatcher) processIssueEvent(ctx context.Context, event *gitlab.IssueEvent) error {
-	return processEvent(ctx, d.issueListeners, IssueListener.OnIssue, event)
-}
+type DispatchRequestOption func(*dispatchRequestOptions)
 
-func (d *Dispatcher) processJobEvent(ctx context.Context, event *gitlab.JobEvent) error {
-	return processEvent(ctx, d.jobListeners, JobListener.OnJob, event)
+func DispatchRequestWithContext(ctx context.Context) DispatchRequestOption {
+	return func(o *dispatchRequestOptions) {
+		o.ctx = ctx
+	}
 }
 
-func (d *Dispatcher) processMemberEvent(ctx context.Context, event *gitlab.MemberEvent) error {
-	return processEvent(ctx, d.memberListeners, MemberListener.OnMember, event)
+func DispatchRequestWithToken(token string) DispatchRequestOption {
+	return func(o *dispatchRequestOptions) {
+		if token != "" {
+			o.tokenStore = TokenSet{token}
+		}
+	}
 }
 
-func (d *Dispatcher) processMergeCommentEvent(ctx context.Context, event *gitlab.MergeCommentEvent) error {
-	return processEvent(ctx, d.mergeCommentListeners, MergeCommentListener.OnMergeComment, event)
+func DispatchRequestWithTokenStore(store TokenStore) DispatchRequestOption {
+	return func(o *dispatchRequestOptions) {
+		o.tokenStore = store
+	}
 }
 
-func (d *Dispatcher) processMergeEvent(ctx context.Context, event *gitlab.MergeEvent) error {
-	return processEvent(ctx, d.mergeListeners, MergeListener.OnMerge, event)
+func (d *Dispatcher) DispatchRequest(req *http.Request, opts ...DispatchRequestOption) error {
+	o := newDispatchRequestOptions(req, opts...)
+	ctx, event, err := d.parseRequest(req, o)
+	if errors.Is(err, errNoListeners) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// the final status depends on which listeners the event is routed to
+	report := o.report
+	if report == nil && o.status != nil {
+		report = &DispatchReport{}
+	}
+	err = d.dispatch(ctx, event, report)
+	if o.status != nil {
+		*o.status = report.Status
+	}
+	if err != nil {
+		return errors.Join(err, releaseDelivery(ctx))
+	}
+	return nil
 }
 
-func (d *Dispatcher) processPipelineEvent(ctx context.Context, event *gitlab.PipelineEvent) error {
-	return processEvent(ctx, d.pipelineListeners, PipelineListener.OnPipeline, event)
-}
+// errNoListeners is returned by parseRequest for deliveries nobody listens
+// for, which are not dispatched.
+var errNoListeners = errors.New("gitlab-webhook: no listeners")
 
-func (d *Dispatcher) processProjectResourceAccessTokenEvent(ctx context.Context, event *gitlab.ProjectResourceAccessTokenEvent) error { //nolint:lll
-	return processEvent(ctx, d.projectResourceAccessTokenListeners, ProjectResourceAccessTokenListener.OnProjectResourceAccessToken, event)
-}
+// parseRequest validates req and decodes its event, returning the context
+// the event should be dispatched with.
+func (d *Dispatcher) parseRequest(req *http.Request, o *dispatchRequestOptions) (context.Context, any, error) {
+	receivedAt := time.Now()
+	if o.status != nil {
+		// until the request is known to be valid
+		*o.status = DispatchStatusRejected
+	}
 
-func (d *Dispatcher) processPushEvent(ctx context.Context, event *gitlab.PushEvent) error {
-	return processEvent(ctx, d.pushListeners, PushListener.OnPush, event)
-}
+	// check token if a token store is provided, reading the body only if
+	// the store needs it
+	body := &requestBody{r: req.Body}
+	if o.tokenStore != nil {
+		tokens, err := o.tokenStore.Tokens(o.ctx, TokenLookup{
+			EventType: gitlab.HookEventType(req),
+			Instance:  req.Header.Get("X-Gitlab-Instance"),
+			body:      body,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if !matchToken(gitlab.HookEventToken(req), tokens) {
+			return nil, nil, ErrInvalidToken
+		}
+	}
 
-func (d *Dispatcher) processReleaseEvent(ctx context.Context, event *gitlab.ReleaseEvent) error {
-	return processEvent(ctx, d.releaseListeners, ReleaseListener.OnRelease, event)
-}
+	// read payload
+	payload, err := body.read()
+	if err != nil {
+		return nil, nil, err
+	}
 
-func (d *Dispatcher) processSnippetCommentEvent(ctx context.Context, event *gitlab.SnippetCommentEvent) error {
-	return processEvent(ctx, d.snippetCommentListeners, SnippetCommentListener.OnSnippetComment, event)
-}
+	// verify signature if signing secrets provided
+	if len(o.signingSecrets) > 0 {
+		if err := verifySignature(req.Header, payload, o.signingSecrets, o.signatureTolerance, receivedAt); err != nil {
+			return nil, nil, err
+		}
+	}
 
-func (d *Dispatcher) processSubGroupEvent(ctx context.Context, event *gitlab.SubGroupEvent) error {
-	return processEvent(ctx, d.subGroupListeners, SubGroupListener.OnSubGroup, event)
-}
+	// skip decoding deliveries nobody listens for
+	if d.skipDecoding(gitlab.HookEventType(req)) {
+		o.setStatus(req, DispatchStatusNoListeners)
+		return nil, nil, errNoListeners
+	}
 
-func (d *Dispatcher) processTagEvent(ctx context.Context, event *gitlab.TagEvent) error {
-	return processEvent(ctx, d.tagListeners, TagListener.OnTag, event)
-}
+	// decode webhook
+	event, err := d.decodeWebhook(gitlab.HookEventType(req), payload)
+	if errors.Is(err, ErrUnsupportedEvent) {
+		o.setStatus(req, DispatchStatusUnsupported)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	dl := newRequestDelivery(req, payload, receivedAt)
+	dl.event = event
+
+	// skip deliveries that were already processed
+	if key := dedupKey(req.Header); o.dedupStore != nil && key != "" {
+		first, err := o.dedupStore.Claim(o.ctx, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !first {
+			o.setStatus(req, DispatchStatusDuplicate)
+			return nil, nil, ErrDuplicateDelivery
+		}
+		dl.dedupStore, dl.dedupKey = o.dedupStore, key
+	}
+	o.setStatus(req, DispatchStatusDispatched)
 
-func (d *Dispatcher) processWikiPageEvent(ctx context.Context, event *gitlab.WikiPageEvent) error {
-	return processEvent(ctx, d.wikiPageListeners, WikiPageListener.OnWikiPage, event)
+	return withDelivery(o.ctx, dl), event, nil
 }
 
-func processEvent[E any, L any](ctx context.Context, listeners []L, handler func(L, context.Context, E) error, event E) error {
-	if len(listeners) == 0 {
+func processEvent(ctx context.Context, d *Dispatcher, eventType gitlab.EventType, listeners []registration, event any, report *DispatchReport) error { //nolint:lll
+	listeners = filterListeners(ctx, listeners, event)
+	var results []ListenerResult
+	if report != nil {
+		results = report.record(len(listeners))
+	}
+	switch len(listeners) {
+	case 0:
 		return nil
+	case 1:
+		return reportListener(ctx, d, eventType, listeners[0], event, results, 0)
 	}
+	return runListeners(ctx, d, eventType, listeners, event, results)
+}
 
+// runListeners runs listeners concurrently and joins their errors.
+func runListeners(ctx context.Context, d *Dispatcher, eventType gitlab.EventType, listeners []registration, event any, results []ListenerResult) error { //nolint:lll
+	// the first listener runs on the calling goroutine, which would otherwise
+	// just wait for the others
+	errs := make([]error, len(listeners))
 	wg := sync.WaitGroup{}
-	wg.Add(len(listeners))
-
-	errCh := make(chan error, len(listeners))
-	for _, listener := range listeners {
-		go func() {
+	wg.Add(len(listeners) - 1)
+	for i := 1; i < len(listeners); i++ {
+		d.run(func() {
 			defer wg.Done()
-			if err := handler(listener, ctx, event); err != nil {
-				errCh <- err
-			}
-		}()
+			errs[i] = reportListener(ctx, d, eventType, listeners[i], event, results, i)
+		})
 	}
+	errs[0] = reportListener(ctx, d, eventType, listeners[0], event, results, 0)
 	wg.Wait()
 
-	close(errCh)
-	var err error
-	for e := range errCh {
-		err = errors.Join(err, e)
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) run(task func()) {
+	if d.pool != nil {
+		d.pool.submit(task)
+		return
 	}
-	return err
+	go task()
 }
@@ -3,13 +3,19 @@ package gitlabwebhook
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	gitlab "gitlab.com/gitlab-org/api/client-go"
 )
 
@@ -60,15 +66,21 @@ func TestDispatcher_Dispatch(t *testing.T) {
 		{"issue", gitlab.EventTypeIssue, "testdata/webhooks/issue.json"},                                                           //nolint:lll
 		{"job", gitlab.EventTypeJob, "testdata/webhooks/job.json"},
 		{"member", gitlab.EventTypeMember, "testdata/webhooks/member.json"},
-		{"merge comment", gitlab.EventTypeNote, "testdata/webhooks/note_merge_request.json"}, //nolint:lll
-		{"merge", gitlab.EventTypeMergeRequest, "testdata/webhooks/merge_request.json"},      //nolint:lll
-		{"pipeline", gitlab.EventTypePipeline, "testdata/webhooks/pipeline.json"},            //nolint:lll
+		{"member access request", gitlab.EventTypeMember, "testdata/webhooks/member_access_request.json"}, //nolint:lll
+		{"merge comment", gitlab.EventTypeNote, "testdata/webhooks/note_merge_request.json"},              //nolint:lll
+		{"merge", gitlab.EventTypeMergeRequest, "testdata/webhooks/merge_request.json"},                   //nolint:lll
+		{"milestone", gitlab.EventTypeMilestone, "testdata/webhooks/milestone.json"},                      //nolint:lll
+		{"milestone (group)", gitlab.EventTypeMilestone, "testdata/webhooks/milestone_group.json"},        //nolint:lll
+		{"pipeline", gitlab.EventTypePipeline, "testdata/webhooks/pipeline.json"},                         //nolint:lll
+		{"project", gitlab.EventTypeProject, "testdata/webhooks/project.json"},                            //nolint:lll
 		{"push", gitlab.EventTypePush, "testdata/webhooks/push.json"},
 		{"release", gitlab.EventTypeRelease, "testdata/webhooks/release.json"},           //nolint:lll
 		{"snippet comment", gitlab.EventTypeNote, "testdata/webhooks/note_snippet.json"}, //nolint:lll
 		{"subgroup", gitlab.EventTypeSubGroup, "testdata/webhooks/subgroup.json"},        //nolint:lll
 		{"tag", gitlab.EventTypeTagPush, "testdata/webhooks/tag_push.json"},
-		{"wiki page", gitlab.EventTypeWikiPage, "testdata/webhooks/wiki_page.json"}, //nolint:lll
+		{"vulnerability", gitlab.EventTypeVulnerability, "testdata/webhooks/vulnerability.json"}, //nolint:lll
+		{"wiki page", gitlab.EventTypeWikiPage, "testdata/webhooks/wiki_page.json"},              //nolint:lll
+		{"work item", gitlab.EventTypeIssue, "testdata/webhooks/work_item.json"},                 //nolint:lll
 	}
 
 	for _, tt := range tests {
@@ -105,16 +117,20 @@ var (
 	_ IssueCommentListener               = (*testListener)(nil)
 	_ IssueListener                      = (*testListener)(nil)
 	_ JobListener                        = (*testListener)(nil)
+	_ MemberAccessRequestListener        = (*testListener)(nil)
 	_ MemberListener                     = (*testListener)(nil)
 	_ MergeCommentListener               = (*testListener)(nil)
 	_ MergeListener                      = (*testListener)(nil)
+	_ MilestoneListener                  = (*testListener)(nil)
 	_ PipelineListener                   = (*testListener)(nil)
+	_ ProjectListener                    = (*testListener)(nil)
 	_ ProjectResourceAccessTokenListener = (*testListener)(nil)
 	_ PushListener                       = (*testListener)(nil)
 	_ ReleaseListener                    = (*testListener)(nil)
 	_ SnippetCommentListener             = (*testListener)(nil)
 	_ SubGroupListener                   = (*testListener)(nil)
 	_ TagListener                        = (*testListener)(nil)
+	_ VulnerabilityListener              = (*testListener)(nil)
 	_ WikiPageListener                   = (*testListener)(nil)
 )
 
@@ -188,6 +204,12 @@ func (t *testListener) OnMember(ctx context.Context, event *gitlab.MemberEvent)
 	return nil
 }
 
+func (t *testListener) OnMemberAccessRequest(ctx context.Context, event *gitlab.MemberEvent) error {
+	testDispatcherContext(ctx, t.t)
+	assert.Equal(t.t, "user_access_request_to_group", event.EventName)
+	return nil
+}
+
 func (t *testListener) OnMergeComment(ctx context.Context, event *gitlab.MergeCommentEvent) error {
 	testDispatcherContext(ctx, t.t)
 	assert.Equal(t.t, "Gitlab Test", event.Project.Name)
@@ -200,12 +222,24 @@ func (t *testListener) OnMerge(ctx context.Context, event *gitlab.MergeEvent) er
 	return nil
 }
 
+func (t *testListener) OnMilestone(ctx context.Context, event *gitlab.MilestoneWebhookEvent) error {
+	testDispatcherContext(ctx, t.t)
+	assert.Equal(t.t, "v1.0.0", event.ObjectAttributes.Title)
+	return nil
+}
+
 func (t *testListener) OnPipeline(ctx context.Context, event *gitlab.PipelineEvent) error {
 	testDispatcherContext(ctx, t.t)
 	assert.Equal(t.t, "Gitlab Test", event.Project.Name)
 	return nil
 }
 
+func (t *testListener) OnProject(ctx context.Context, event *gitlab.ProjectWebhookEvent) error {
+	testDispatcherContext(ctx, t.t)
+	assert.Equal(t.t, "Flight", event.Name)
+	return nil
+}
+
 func (t *testListener) OnProjectResourceAccessToken(ctx context.Context, event *gitlab.ProjectResourceAccessTokenEvent) error { //nolint:lll
 	testDispatcherContext(ctx, t.t)
 	assert.Equal(t.t, "expiring_access_token", event.EventName)
@@ -242,6 +276,20 @@ func (t *testListener) OnTag(ctx context.Context, event *gitlab.TagEvent) error
 	return nil
 }
 
+func (t *testListener) OnWorkItem(ctx context.Context, event *WorkItemEvent) error {
+	testDispatcherContext(ctx, t.t)
+	assert.Equal(t.t, "Task", event.ObjectAttributes.Type)
+	assert.Equal(t.t, "Epic", event.ObjectAttributes.Parent.Type)
+	assert.Equal(t.t, "In progress", event.ObjectAttributes.Status.Name)
+	return nil
+}
+
+func (t *testListener) OnVulnerability(ctx context.Context, event *gitlab.VulnerabilityEvent) error {
+	testDispatcherContext(ctx, t.t)
+	assert.Equal(t.t, "Potential SQL Injection", event.ObjectAttributes.Title)
+	return nil
+}
+
 func (t *testListener) OnWikiPage(ctx context.Context, event *gitlab.WikiPageEvent) error {
 	testDispatcherContext(ctx, t.t)
 	assert.Equal(t.t, "awesome-project", event.Project.Name)
@@ -346,3 +394,286 @@ func loadFixture(filePath string) []byte {
 
 	return content
 }
+
+type countingPushListener struct {
+	calls atomic.Int64
+	err   error
+}
+
+func (c *countingPushListener) OnPush(context.Context, *gitlab.PushEvent) error {
+	c.calls.Add(1)
+	return c.err
+}
+
+func TestDispatcher_WithConcurrency(t *testing.T) {
+	errFailed := errors.New("failed")
+	listeners := make([]any, 0, 16)
+	counters := make([]*countingPushListener, 0, 16)
+	for i := range 16 {
+		l := &countingPushListener{}
+		if i%4 == 0 {
+			l.err = errFailed
+		}
+		listeners = append(listeners, l)
+		counters = append(counters, l)
+	}
+
+	dispatcher := NewDispatcher(WithConcurrency(2), RegisterListeners(listeners...))
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{})
+			assert.ErrorIs(t, err, errFailed)
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range counters {
+		assert.Equal(t, int64(8), c.calls.Load())
+	}
+}
+
+func benchmarkDispatch(b *testing.B, listeners int, opts ...Option) {
+	for range listeners {
+		opts = append(opts, RegisterListeners(&countingPushListener{}))
+	}
+	dispatcher := NewDispatcher(opts...)
+	event := &gitlab.PushEvent{}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if err := dispatcher.Dispatch(ctx, event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDispatcher_Dispatch(b *testing.B) {
+	b.Run("single listener", func(b *testing.B) {
+		benchmarkDispatch(b, 1)
+	})
+	b.Run("8 listeners", func(b *testing.B) {
+		benchmarkDispatch(b, 8)
+	})
+	b.Run("8 listeners with concurrency 4", func(b *testing.B) {
+		benchmarkDispatch(b, 8, WithConcurrency(4))
+	})
+}
+
+func BenchmarkDispatcher_DispatchParallel(b *testing.B) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"goroutine per listener", nil},
+		{"concurrency 4", []Option{WithConcurrency(4)}},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			opts := tt.opts
+			for range 8 {
+				opts = append(opts, RegisterListeners(&countingPushListener{}))
+			}
+			dispatcher := NewDispatcher(opts...)
+			event := &gitlab.PushEvent{}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_ = dispatcher.Dispatch(context.Background(), event)
+				}
+			})
+		})
+	}
+}
+
+type memberTestListener struct {
+	members        []string
+	accessRequests []string
+}
+
+func (m *memberTestListener) OnMember(_ context.Context, event *gitlab.MemberEvent) error {
+	m.members = append(m.members, event.EventName)
+	return nil
+}
+
+func (m *memberTestListener) OnMemberAccessRequest(_ context.Context, event *gitlab.MemberEvent) error {
+	m.accessRequests = append(m.accessRequests, event.EventName)
+	return nil
+}
+
+func TestDispatcher_DispatchMemberAccessRequest(t *testing.T) {
+	listener := &memberTestListener{}
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeMember, loadFixture("testdata/webhooks/member.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeMember, loadFixture("testdata/webhooks/member_access_request.json"))) //nolint:lll
+
+	assert.Equal(t, []string{"user_add_to_group", "user_access_request_to_group"}, listener.members)
+	assert.Equal(t, []string{"user_access_request_to_group"}, listener.accessRequests)
+}
+
+type confidentialTestListener struct {
+	issues               []bool
+	confidentialIssues   []bool
+	comments             []bool
+	confidentialComments []bool
+}
+
+func (l *confidentialTestListener) OnIssue(ctx context.Context, _ *gitlab.IssueEvent) error {
+	l.issues = append(l.issues, IsConfidential(ctx))
+	return nil
+}
+
+func (l *confidentialTestListener) OnConfidentialIssue(ctx context.Context, _ *gitlab.IssueEvent) error {
+	l.confidentialIssues = append(l.confidentialIssues, IsConfidential(ctx))
+	return nil
+}
+
+func (l *confidentialTestListener) OnIssueComment(ctx context.Context, _ *gitlab.IssueCommentEvent) error {
+	l.comments = append(l.comments, IsConfidential(ctx))
+	return nil
+}
+
+func (l *confidentialTestListener) OnConfidentialNote(ctx context.Context, _ *gitlab.IssueCommentEvent) error {
+	l.confidentialComments = append(l.confidentialComments, IsConfidential(ctx))
+	return nil
+}
+
+func dispatchConfidentialFixtures(t *testing.T, dispatcher *Dispatcher) {
+	t.Helper()
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, loadFixture("testdata/webhooks/issue.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventConfidentialIssue, loadFixture("testdata/webhooks/confidential_issue.json"))) //nolint:lll
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeNote, loadFixture("testdata/webhooks/note_issue.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventConfidentialNote, loadFixture("testdata/webhooks/confidential_note.json"))) //nolint:lll
+}
+
+func TestDispatcher_DispatchConfidential(t *testing.T) {
+	listener := &confidentialTestListener{}
+	dispatchConfidentialFixtures(t, NewDispatcher(RegisterListeners(listener)))
+
+	assert.Equal(t, []bool{false, true}, listener.issues)
+	assert.Equal(t, []bool{true}, listener.confidentialIssues)
+	assert.Equal(t, []bool{false, true}, listener.comments)
+	assert.Equal(t, []bool{true}, listener.confidentialComments)
+}
+
+func TestDispatcher_DispatchConfidentialToIssueListener(t *testing.T) {
+	var issues int
+	dispatcher := NewDispatcher(RegisterListeners(IssueListenerFunc(func(context.Context, *gitlab.IssueEvent) error {
+		issues++
+		return nil
+	})))
+
+	payload := loadFixture("testdata/webhooks/confidential_issue.json")
+	require.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventConfidentialIssue, payload))
+	assert.Equal(t, 1, issues)
+}
+
+func TestDispatcher_DispatchConfidentialListenersOnly(t *testing.T) {
+	listener := &confidentialTestListener{}
+	dispatchConfidentialFixtures(t, NewDispatcher(RegisterListeners(listener), WithConfidentialListenersOnly()))
+
+	assert.Equal(t, []bool{false}, listener.issues)
+	assert.Equal(t, []bool{true}, listener.confidentialIssues)
+	assert.Equal(t, []bool{false}, listener.comments)
+	assert.Equal(t, []bool{true}, listener.confidentialComments)
+}
+
+func TestDispatcher_DispatchConfidentialWithoutDelivery(t *testing.T) {
+	listener := &confidentialTestListener{}
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+
+	event, err := gitlab.ParseWebhook(gitlab.EventConfidentialIssue, loadFixture("testdata/webhooks/confidential_issue.json"))
+	require.NoError(t, err)
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), event))
+
+	assert.Equal(t, []bool{true}, listener.issues)
+	assert.Equal(t, []bool{true}, listener.confidentialIssues)
+}
+
+type workItemTestListener struct {
+	issues    int
+	workItems []string
+}
+
+func (l *workItemTestListener) OnIssue(context.Context, *gitlab.IssueEvent) error {
+	l.issues++
+	return nil
+}
+
+func (l *workItemTestListener) OnWorkItem(_ context.Context, event *WorkItemEvent) error {
+	l.workItems = append(l.workItems, event.ObjectAttributes.Type)
+	return nil
+}
+
+func TestDispatcher_DispatchWorkItem(t *testing.T) {
+	listener := &workItemTestListener{}
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, loadFixture("testdata/webhooks/issue.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, loadFixture("testdata/webhooks/work_item.json")))
+
+	assert.Equal(t, 1, listener.issues)
+	assert.Equal(t, []string{"Task"}, listener.workItems)
+}
+
+type anyTestListener struct {
+	mu     sync.Mutex
+	events []gitlab.EventType
+	raw    []json.RawMessage
+}
+
+func (l *anyTestListener) OnEvent(_ context.Context, eventType gitlab.EventType, event any) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.events = append(l.events, eventType)
+	if raw, ok := event.(json.RawMessage); ok {
+		l.raw = append(l.raw, raw)
+	}
+	return nil
+}
+
+func TestDispatcher_AnyListener(t *testing.T) {
+	pushes := &countingPushListener{}
+	listener := &anyTestListener{}
+	dispatcher := NewDispatcher(RegisterListeners(pushes, listener))
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypePush, loadFixture("testdata/webhooks/push.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, loadFixture("testdata/webhooks/issue.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, "Unknown Hook", []byte(`{"object_kind":"unknown"}`)))
+
+	var event gitlab.TagEvent
+	assert.NoError(t, dispatcher.Dispatch(ctx, &event))
+
+	assert.Equal(t, int64(1), pushes.calls.Load())
+	assert.Equal(t, []gitlab.EventType{gitlab.EventTypePush, gitlab.EventTypeIssue, "Unknown Hook", gitlab.EventTypeTagPush}, listener.events) //nolint:lll
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"object_kind":"unknown"}`)}, listener.raw)
+}
+
+func TestDispatcher_AnyListenerRequest(t *testing.T) {
+	listener := &anyTestListener{}
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"object_kind":"unknown"}`))
+		req.Header.Set("X-Gitlab-Event", "Unknown Hook")
+		return req
+	}
+
+	assert.NoError(t, dispatcher.DispatchRequest(newRequest()))
+	assert.Equal(t, []gitlab.EventType{"Unknown Hook"}, listener.events)
+
+	assert.ErrorIs(t, NewDispatcher().DispatchRequest(newRequest()), ErrUnsupportedEvent)
+}
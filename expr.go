@@ -0,0 +1,593 @@
+package gitlabwebhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Expr is a compiled filter expression evaluated against the JSON payload of
+// an event. Expressions combine comparisons of payload fields with &&, || and
+// !, for example:
+//
+//	object_kind == "pipeline" && object_attributes.status in ["failed", "canceled"]
+//
+// Fields are dot separated paths into the payload. A path that reaches an
+// array continues into every element, or into one element if the segment is
+// an index, so labels.title is the list of label titles and commits.0.id the
+// id of the first commit. Missing fields are null.
+//
+// The operators are == and != on any value, <, <=, > and >= on numbers and
+// strings, in to test membership of a list literal or array field, and
+// matches to match a string against a glob like those of WithProject.
+// Literals are double quoted strings, numbers, true, false and null.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// ExprError reports an invalid expression and where in it the problem is.
+type ExprError struct {
+	Expr string
+	// Pos is the byte offset in Expr at which the problem was found.
+	Pos int
+	Msg string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("gitlab-webhook: invalid expression at column %d: %s", e.Pos+1, e.Msg)
+}
+
+// CompileExpr parses and validates an expression.
+func CompileExpr(src string) (*Expr, error) {
+	p := &exprParser{lexer: exprLexer{src: src}}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// MustCompileExpr is like CompileExpr but panics if the expression is invalid.
+func MustCompileExpr(src string) *Expr {
+	expr, err := CompileExpr(src)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+// WithExpr only invokes the listener for events whose payload matches expr.
+func WithExpr(expr *Expr) ListenerOption {
+	return withFilter(func(e *filterEvent) bool {
+		return expr.eval(e.payload())
+	})
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Match reports whether a JSON payload matches the expression.
+func (e *Expr) Match(payload []byte) (bool, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return false, err
+	}
+	return e.eval(fields), nil
+}
+
+func (e *Expr) eval(fields map[string]any) bool {
+	return e.root.eval(fields) == true
+}
+
+type exprNode interface {
+	eval(fields map[string]any) any
+}
+
+type (
+	literalNode struct{ value any }
+	pathNode    struct{ path []string }
+	listNode    struct{ items []any }
+	notNode     struct{ operand exprNode }
+	logicalNode struct {
+		and         bool
+		left, right exprNode
+	}
+	compareNode struct {
+		op          string
+		left, right exprNode
+	}
+	matchesNode struct {
+		left exprNode
+		glob *regexp.Regexp
+	}
+)
+
+func (n literalNode) eval(map[string]any) any { return n.value }
+
+func (n pathNode) eval(fields map[string]any) any { return resolvePath(fields, n.path) }
+
+func (n listNode) eval(map[string]any) any { return n.items }
+
+func (n notNode) eval(fields map[string]any) any { return n.operand.eval(fields) != true }
+
+func (n logicalNode) eval(fields map[string]any) any {
+	left := n.left.eval(fields) == true
+	if left != n.and {
+		return left
+	}
+	return n.right.eval(fields) == true
+}
+
+func (n compareNode) eval(fields map[string]any) any {
+	left, right := n.left.eval(fields), n.right.eval(fields)
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right)
+	case "!=":
+		return !valuesEqual(left, right)
+	case "in":
+		items, _ := right.([]any)
+		for _, item := range items {
+			if valuesEqual(left, item) {
+				return true
+			}
+		}
+		return false
+	}
+
+	c, ok := compareValues(left, right)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func (n matchesNode) eval(fields map[string]any) any {
+	s, ok := n.left.eval(fields).(string)
+	return ok && n.glob.MatchString(s)
+}
+
+func resolvePath(value any, path []string) any {
+	for i, key := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			value = v[key]
+		case []any:
+			if index, err := strconv.Atoi(key); err == nil {
+				if index < 0 || index >= len(v) {
+					return nil
+				}
+				value = v[index]
+				continue
+			}
+			values := make([]any, 0, len(v))
+			for _, item := range v {
+				if resolved := resolvePath(item, path[i:]); resolved != nil {
+					values = append(values, resolved)
+				}
+			}
+			return values
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+func valuesEqual(a, b any) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case float64:
+		b, ok := b.(float64)
+		return ok && a == b
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	default:
+		return false
+	}
+}
+
+func compareValues(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+	return 0, false
+}
+
+type exprTokenKind int
+
+const (
+	tokenEOF exprTokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenPunct
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	value any
+	pos   int
+}
+
+func (t exprToken) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+type exprLexer struct {
+	src string
+	pos int
+}
+
+func (l *exprLexer) errorf(pos int, format string, args ...any) error {
+	return &ExprError{Expr: l.src, Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (l *exprLexer) next() (exprToken, error) {
+	for l.pos < len(l.src) && strings.IndexByte(" \t\r\n", l.src[l.pos]) >= 0 {
+		l.pos++
+	}
+	start := l.pos
+	if start == len(l.src) {
+		return exprToken{kind: tokenEOF, pos: start}, nil
+	}
+
+	c := l.src[start]
+	switch {
+	case c == '"':
+		return l.string()
+	case isDigit(c) || (c == '-' && start+1 < len(l.src) && isDigit(l.src[start+1])):
+		return l.number()
+	case isIdentStart(c):
+		return l.ident()
+	case strings.IndexByte("()[],", c) >= 0:
+		l.pos++
+		return exprToken{kind: tokenPunct, text: string(c), pos: start}, nil
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"} {
+		if strings.HasPrefix(l.src[start:], op) {
+			l.pos += len(op)
+			return exprToken{kind: tokenOperator, text: op, pos: start}, nil
+		}
+	}
+	return exprToken{}, l.errorf(start, "unexpected character %q", c)
+}
+
+func (l *exprLexer) string() (exprToken, error) {
+	start := l.pos
+	for i := start + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case '"':
+			text := l.src[start : i+1]
+			value, err := strconv.Unquote(text)
+			if err != nil {
+				return exprToken{}, l.errorf(start, "invalid string %s", text)
+			}
+			l.pos = i + 1
+			return exprToken{kind: tokenString, text: text, value: value, pos: start}, nil
+		}
+	}
+	return exprToken{}, l.errorf(start, "unterminated string")
+}
+
+func (l *exprLexer) number() (exprToken, error) {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+		l.pos++
+	}
+	text := l.src[start:l.pos]
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return exprToken{}, l.errorf(start, "invalid number %s", text)
+	}
+	return exprToken{kind: tokenNumber, text: text, value: value, pos: start}, nil
+}
+
+func (l *exprLexer) ident() (exprToken, error) {
+	start := l.pos
+	for l.pos < len(l.src) && (isIdentStart(l.src[l.pos]) || isDigit(l.src[l.pos]) || l.src[l.pos] == '.') {
+		l.pos++
+	}
+	text := l.src[start:l.pos]
+	if strings.HasSuffix(text, ".") || strings.Contains(text, "..") {
+		return exprToken{}, l.errorf(start, "invalid field path %s", text)
+	}
+	return exprToken{kind: tokenIdent, text: text, pos: start}, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type exprParser struct {
+	lexer exprLexer
+	token exprToken
+}
+
+// operand kinds checked at compile time
+const (
+	operandBool = iota
+	operandValue
+	operandList
+	operandAny
+)
+
+type operand struct {
+	node exprNode
+	kind int
+	pos  int
+}
+
+func (p *exprParser) parse() (exprNode, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	if err := p.requireBool(expr); err != nil {
+		return nil, err
+	}
+	return expr.node, nil
+}
+
+func (p *exprParser) advance() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *exprParser) unexpected() error {
+	return p.lexer.errorf(p.token.pos, "unexpected %s", p.token)
+}
+
+func (p *exprParser) requireBool(o operand) error {
+	if o.kind == operandBool || o.kind == operandAny {
+		return nil
+	}
+	return p.lexer.errorf(o.pos, "expected a condition")
+}
+
+func (p *exprParser) or() (operand, error) {
+	return p.logical("||", p.and)
+}
+
+func (p *exprParser) and() (operand, error) {
+	return p.logical("&&", p.not)
+}
+
+func (p *exprParser) logical(op string, next func() (operand, error)) (operand, error) {
+	left, err := next()
+	if err != nil {
+		return operand{}, err
+	}
+	for p.token.kind == tokenOperator && p.token.text == op {
+		if err := p.requireBool(left); err != nil {
+			return operand{}, err
+		}
+		if err := p.advance(); err != nil {
+			return operand{}, err
+		}
+		right, err := next()
+		if err != nil {
+			return operand{}, err
+		}
+		if err := p.requireBool(right); err != nil {
+			return operand{}, err
+		}
+		left = operand{node: logicalNode{and: op == "&&", left: left.node, right: right.node}, kind: operandBool, pos: left.pos}
+	}
+	return left, nil
+}
+
+func (p *exprParser) not() (operand, error) {
+	if p.token.kind != tokenOperator || p.token.text != "!" {
+		return p.comparison()
+	}
+	pos := p.token.pos
+	if err := p.advance(); err != nil {
+		return operand{}, err
+	}
+	o, err := p.not()
+	if err != nil {
+		return operand{}, err
+	}
+	if err := p.requireBool(o); err != nil {
+		return operand{}, err
+	}
+	return operand{node: notNode{operand: o.node}, kind: operandBool, pos: pos}, nil
+}
+
+func (p *exprParser) comparison() (operand, error) {
+	left, err := p.operand()
+	if err != nil {
+		return operand{}, err
+	}
+
+	op := p.token
+	if !isComparison(op) {
+		return left, nil
+	}
+	if left.kind == operandList {
+		return operand{}, p.lexer.errorf(left.pos, "a list can only be the right operand of in")
+	}
+	if err := p.advance(); err != nil {
+		return operand{}, err
+	}
+	right, err := p.operand()
+	if err != nil {
+		return operand{}, err
+	}
+
+	switch op.text {
+	case "in":
+		if right.kind != operandList && right.kind != operandAny {
+			return operand{}, p.lexer.errorf(right.pos, "in requires a list or a field")
+		}
+	case "matches":
+		lit, ok := right.node.(literalNode)
+		pattern, isString := lit.value.(string)
+		if !ok || !isString {
+			return operand{}, p.lexer.errorf(right.pos, "matches requires a string pattern")
+		}
+		return operand{node: matchesNode{left: left.node, glob: compileGlob(pattern)}, kind: operandBool, pos: left.pos}, nil
+	default:
+		if right.kind == operandList {
+			return operand{}, p.lexer.errorf(right.pos, "a list can only be the right operand of in")
+		}
+	}
+	return operand{node: compareNode{op: op.text, left: left.node, right: right.node}, kind: operandBool, pos: left.pos}, nil
+}
+
+func isComparison(token exprToken) bool {
+	switch token.kind {
+	case tokenOperator:
+		switch token.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+			return true
+		}
+	case tokenIdent:
+		return token.text == "in" || token.text == "matches"
+	}
+	return false
+}
+
+func (p *exprParser) operand() (operand, error) {
+	token := p.token
+	switch token.kind {
+	case tokenString, tokenNumber:
+		return p.literal(token.value, operandValue)
+	case tokenIdent:
+		switch token.text {
+		case "true", "false":
+			return p.literal(token.text == "true", operandBool)
+		case "null":
+			return p.literal(nil, operandValue)
+		case "in", "matches":
+			return operand{}, p.unexpected()
+		}
+		if err := p.advance(); err != nil {
+			return operand{}, err
+		}
+		return operand{node: pathNode{path: strings.Split(token.text, ".")}, kind: operandAny, pos: token.pos}, nil
+	case tokenPunct:
+		switch token.text {
+		case "(":
+			return p.group()
+		case "[":
+			return p.list()
+		}
+	}
+	return operand{}, p.unexpected()
+}
+
+func (p *exprParser) literal(value any, kind int) (operand, error) {
+	pos := p.token.pos
+	if err := p.advance(); err != nil {
+		return operand{}, err
+	}
+	return operand{node: literalNode{value: value}, kind: kind, pos: pos}, nil
+}
+
+func (p *exprParser) group() (operand, error) {
+	pos := p.token.pos
+	if err := p.advance(); err != nil {
+		return operand{}, err
+	}
+	o, err := p.or()
+	if err != nil {
+		return operand{}, err
+	}
+	if p.token.kind != tokenPunct || p.token.text != ")" {
+		return operand{}, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return operand{}, err
+	}
+	o.pos = pos
+	return o, nil
+}
+
+func (p *exprParser) list() (operand, error) {
+	pos := p.token.pos
+	if err := p.advance(); err != nil {
+		return operand{}, err
+	}
+
+	items := []any{}
+	for p.token.kind != tokenPunct || p.token.text != "]" {
+		if len(items) > 0 {
+			if p.token.kind != tokenPunct || p.token.text != "," {
+				return operand{}, p.unexpected()
+			}
+			if err := p.advance(); err != nil {
+				return operand{}, err
+			}
+		}
+		item, err := p.operand()
+		if err != nil {
+			return operand{}, err
+		}
+		lit, ok := item.node.(literalNode)
+		if !ok {
+			return operand{}, p.lexer.errorf(item.pos, "lists can only contain literals")
+		}
+		items = append(items, lit.value)
+	}
+	if err := p.advance(); err != nil {
+		return operand{}, err
+	}
+	return operand{node: listNode{items: items}, kind: operandList, pos: pos}, nil
+}
@@ -0,0 +1,103 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestExpr_Match(t *testing.T) {
+	payload := loadFixture("testdata/webhooks/pipeline.json")
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`object_kind == "pipeline"`, true},
+		{`object_kind != "pipeline"`, false},
+		{`object_kind == "pipeline" && object_attributes.status in ["failed", "canceled"]`, false},
+		{`object_kind == "pipeline" && object_attributes.status in ["success", "failed"]`, true},
+		{`project.path_with_namespace matches "gitlab-org/**"`, true},
+		{`project.path_with_namespace matches "infra/**"`, false},
+		{`object_attributes.id >= 31 && object_attributes.id < 32`, true},
+		{`object_attributes.id > 31`, false},
+		{`object_attributes.ref <= "master"`, true},
+		{`"production" in builds.name`, true},
+		{`"deploy" in builds.name`, false},
+		{`builds.0.name == "production"`, true},
+		{`builds.10.name == null`, true},
+		{`missing.field == null`, true},
+		{`missing.field`, false},
+		{`!(object_kind == "push") || false`, true},
+		{`object_kind == "push" || object_attributes.status == "success" && project.id == 1`, true},
+		{`object_attributes.id == "31"`, false},
+		{`object_attributes.tag == false`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := CompileExpr(tt.expr)
+			require.NoError(t, err)
+
+			got, err := expr.Match(payload)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCompileExpr_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		pos  int
+		msg  string
+	}{
+		{``, 0, `unexpected end of expression`},
+		{`object_kind ==`, 14, `unexpected end of expression`},
+		{`object_kind = "push"`, 12, `unexpected character '='`},
+		{`object_kind == "push`, 15, `unterminated string`},
+		{`object_kind == "push" &&`, 24, `unexpected end of expression`},
+		{`(object_kind == "push"`, 22, `unexpected end of expression`},
+		{`object_kind == "push")`, 21, `unexpected ")"`},
+		{`"push"`, 0, `expected a condition`},
+		{`object_kind == "push" && 1`, 25, `expected a condition`},
+		{`object_kind matches 1`, 20, `matches requires a string pattern`},
+		{`object_kind in "push"`, 15, `in requires a list or a field`},
+		{`object_kind == ["push"]`, 15, `a list can only be the right operand of in`},
+		{`object_kind in ["push", object_kind]`, 24, `lists can only contain literals`},
+		{`object..kind == "push"`, 0, `invalid field path object..kind`},
+		{`object_attributes.id == 1.2.3`, 24, `invalid number 1.2.3`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := CompileExpr(tt.expr)
+
+			var exprErr *ExprError
+			require.ErrorAs(t, err, &exprErr)
+			assert.Equal(t, tt.expr, exprErr.Expr)
+			assert.Equal(t, tt.pos, exprErr.Pos)
+			assert.Equal(t, tt.msg, exprErr.Msg)
+		})
+	}
+
+	assert.Panics(t, func() { MustCompileExpr(`object_kind ==`) })
+}
+
+func TestDispatcher_RegisterWithExpr(t *testing.T) {
+	var statuses []string
+	dispatcher := NewDispatcher()
+	On(dispatcher, func(_ context.Context, event *gitlab.PipelineEvent) error {
+		statuses = append(statuses, event.ObjectAttributes.Status)
+		return nil
+	}, WithExpr(MustCompileExpr(`object_attributes.status in ["failed", "canceled"]`)))
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypePipeline, loadFixture("testdata/webhooks/pipeline.json")))
+	assert.NoError(t, dispatcher.Dispatch(ctx, &gitlab.PipelineEvent{ObjectAttributes: gitlab.PipelineEventObjectAttributes{Status: "failed"}})) //nolint:lll
+
+	assert.Equal(t, []string{"failed"}, statuses)
+}
@@ -0,0 +1,223 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ListenerOption configures a listener when it is registered.
+type ListenerOption func(*listenerOptions)
+
+type listenerOptions struct {
+	filters []eventFilter
+}
+
+func newListenerOptions(opts []ListenerOption) listenerOptions {
+	o := listenerOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// eventFilter reports whether a listener is invoked for an event.
+type eventFilter func(e *filterEvent) bool
+
+// WithProject only invokes the listener for events of a project whose path
+// matches one of patterns. Patterns are globs in which * matches within a
+// path segment and ** across segments, such as "group/*" or "group/**".
+func WithProject(patterns ...string) ListenerOption {
+	globs := compileGlobs(patterns)
+	return withFilter(func(e *filterEvent) bool {
+		return matchAny(globs, e.projectPath())
+	})
+}
+
+// WithRef only invokes the listener for events on a ref that matches one of
+// patterns, globs like those of WithProject. Branch names, as delivered with
+// pipeline and merge request events, also match as "refs/heads/<name>".
+func WithRef(patterns ...string) ListenerOption {
+	globs := compileGlobs(patterns)
+	return withFilter(func(e *filterEvent) bool {
+		ref := e.ref()
+		if ref == "" {
+			return false
+		}
+		return matchAny(globs, ref) || (!strings.HasPrefix(ref, "refs/") && matchAny(globs, "refs/heads/"+ref))
+	})
+}
+
+// WithAction only invokes the listener for events with one of actions, such
+// as "open" or "merge" for merge requests, or with one of the event names of
+// system hooks.
+func WithAction(actions ...string) ListenerOption {
+	return withFilter(func(e *filterEvent) bool {
+		return slices.Contains(actions, e.action())
+	})
+}
+
+// WithLabel only invokes the listener for events of an issue or merge request
+// with label. Using it more than once requires all labels.
+func WithLabel(label string) ListenerOption {
+	return withFilter(func(e *filterEvent) bool {
+		return slices.Contains(e.labels(), label)
+	})
+}
+
+func withFilter(filter eventFilter) ListenerOption {
+	return func(o *listenerOptions) {
+		o.filters = append(o.filters, filter)
+	}
+}
+
+// filterListeners returns the listeners whose filters match event.
+func filterListeners(ctx context.Context, listeners []registration, event any) []registration {
+	if !slices.ContainsFunc(listeners, func(r registration) bool { return len(r.filters) > 0 }) {
+		return listeners
+	}
+
+	e := &filterEvent{ctx: ctx, event: event}
+	matched := make([]registration, 0, len(listeners))
+	for _, r := range listeners {
+		if r.matches(e) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+func (r registration) matches(e *filterEvent) bool {
+	for _, filter := range r.filters {
+		if !filter(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterEvent gives filters access to the fields of an event's JSON payload,
+// decoded once for all of its listeners.
+type filterEvent struct {
+	ctx     context.Context
+	event   any
+	decoded bool
+	fields  map[string]any
+}
+
+func (e *filterEvent) payload() map[string]any {
+	if e.decoded {
+		return e.fields
+	}
+	e.decoded = true
+
+	var payload []byte
+	if dl, ok := DeliveryFromContext(e.ctx); ok && dl.Payload != nil {
+		payload = dl.Payload
+	} else if raw, ok := e.event.(json.RawMessage); ok {
+		payload = raw
+	} else if b, err := json.Marshal(e.event); err == nil {
+		payload = b
+	}
+	_ = json.Unmarshal(payload, &e.fields)
+	return e.fields
+}
+
+func (e *filterEvent) projectPath() string {
+	return firstString(e.payload(), "project.path_with_namespace", "path_with_namespace", "project_path_with_namespace")
+}
+
+func (e *filterEvent) ref() string {
+	return firstString(e.payload(), "ref", "object_attributes.ref", "object_attributes.target_branch")
+}
+
+func (e *filterEvent) action() string {
+	return firstString(e.payload(), "object_attributes.action", "action", "event_name")
+}
+
+func (e *filterEvent) labels() []string {
+	var labels []string
+	for _, path := range []string{"labels", "object_attributes.labels"} {
+		list, _ := lookup(e.payload(), path).([]any)
+		for _, label := range list {
+			if l, ok := label.(map[string]any); ok {
+				if title, ok := l["title"].(string); ok && !slices.Contains(labels, title) {
+					labels = append(labels, title)
+				}
+			}
+		}
+	}
+	return labels
+}
+
+// lookup returns the value at a dot separated path of object keys.
+func lookup(fields map[string]any, path string) any {
+	var value any = fields
+	for key := range strings.SplitSeq(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func firstString(fields map[string]any, paths ...string) string {
+	for _, path := range paths {
+		if s, ok := lookup(fields, path).(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func compileGlobs(patterns []string) []*regexp.Regexp {
+	globs := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		globs = append(globs, compileGlob(pattern))
+	}
+	return globs
+}
+
+// compileGlob translates a glob into a regular expression: ** matches any
+// sequence, * any sequence without a slash and ? a single character other
+// than a slash.
+func compileGlob(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for pattern != "" {
+		i := strings.IndexAny(pattern, "*?")
+		if i < 0 {
+			b.WriteString(regexp.QuoteMeta(pattern))
+			break
+		}
+		b.WriteString(regexp.QuoteMeta(pattern[:i]))
+		switch {
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		default:
+			b.WriteString("[^/]")
+		}
+		pattern = pattern[i+1:]
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func matchAny(globs []*regexp.Regexp, s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, glob := range globs {
+		if glob.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{"group/project", "group/project", true},
+		{"group/*", "group/project", true},
+		{"group/*", "group/sub/project", false},
+		{"group/**", "group/sub/project", true},
+		{"**/project", "group/sub/project", true},
+		{"group/proj?ct", "group/project", true},
+		{"group/?", "group/ab", false},
+		{"refs/heads/release-*", "refs/heads/release-1.0", true},
+		{"a.b", "axb", false},
+		{"grüppe/*", "grüppe/project", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.s, func(t *testing.T) {
+			assert.Equal(t, tt.want, compileGlob(tt.pattern).MatchString(tt.s))
+		})
+	}
+}
+
+func TestDispatcher_RegisterWithFilters(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType gitlab.EventType
+		fixture   string
+		opts      []ListenerOption
+		want      bool
+	}{
+		{"project", gitlab.EventTypePush, "push.json", []ListenerOption{WithProject("mike/*")}, true},
+		{"other project", gitlab.EventTypePush, "push.json", []ListenerOption{WithProject("gitlab-org/*")}, false},
+		{"any of projects", gitlab.EventTypePush, "push.json", []ListenerOption{WithProject("gitlab-org/*", "mike/**")}, true},
+		{"ref", gitlab.EventTypePush, "push.json", []ListenerOption{WithRef("refs/heads/master")}, true},
+		{"other ref", gitlab.EventTypePush, "push.json", []ListenerOption{WithRef("refs/heads/main")}, false},
+		{"branch name", gitlab.EventTypePipeline, "pipeline.json", []ListenerOption{WithRef("refs/heads/master")}, true},
+		{"merge request target", gitlab.EventTypeMergeRequest, "merge_request.json", []ListenerOption{WithRef("master")}, true},
+		{"no ref", gitlab.EventTypeIssue, "issue.json", []ListenerOption{WithRef("**")}, false},
+		{"action", gitlab.EventTypeMergeRequest, "merge_request.json", []ListenerOption{WithAction("merge", "open")}, true},
+		{"other action", gitlab.EventTypeMergeRequest, "merge_request.json", []ListenerOption{WithAction("merge")}, false},
+		{"label", gitlab.EventTypeIssue, "issue.json", []ListenerOption{WithLabel("API")}, true},
+		{"all labels", gitlab.EventTypeIssue, "issue.json", []ListenerOption{WithLabel("API"), WithLabel("deploy")}, false},
+		{"all filters", gitlab.EventTypeMergeRequest, "merge_request.json", []ListenerOption{WithProject("gitlabhq/*"), WithRef("master"), WithAction("open"), WithLabel("API")}, true}, //nolint:lll
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			dispatcher := NewDispatcher()
+			dispatcher.RegisterAnyListener(AnyListenerFunc(func(context.Context, gitlab.EventType, any) error {
+				called = true
+				return nil
+			}), tt.opts...)
+
+			assert.NoError(t, dispatcher.DispatchWebhook(context.Background(), tt.eventType, loadFixture("testdata/webhooks/"+tt.fixture)))
+			assert.Equal(t, tt.want, called)
+		})
+	}
+}
+
+func TestDispatcher_RegisterListenersWithFilters(t *testing.T) {
+	matching, other := &countingPushListener{}, &countingPushListener{}
+	dispatcher := NewDispatcher(RegisterListeners(matching, WithProject("mike/diaspora")))
+	dispatcher.RegisterListeners(WithRef("refs/heads/main"), other)
+	On(dispatcher, func(context.Context, *gitlab.PushEvent) error {
+		t.Error("filtered listener invoked")
+		return nil
+	}, WithProject("gitlab-org/**"))
+
+	// without a delivery the filters see the marshalled event
+	event := &gitlab.PushEvent{Ref: "refs/heads/master"}
+	event.Project.PathWithNamespace = "mike/diaspora"
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), event))
+
+	assert.Equal(t, int64(1), matching.calls.Load())
+	assert.Equal(t, int64(0), other.calls.Load())
+}
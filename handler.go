@@ -0,0 +1,188 @@
+package gitlabwebhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const defaultRetryAfter = 10 * time.Second
+
+// ErrorWriter writes the response for a delivery that could not be dispatched.
+type ErrorWriter func(w http.ResponseWriter, r *http.Request, status int, err error)
+
+// SuccessWriter writes the response for a delivery that was dispatched successfully.
+type SuccessWriter func(w http.ResponseWriter, r *http.Request)
+
+type handlerOptions struct {
+	requestOpts            []DispatchRequestOption
+	unsupportedEventStatus int
+	errorWriter            ErrorWriter
+	successWriter          SuccessWriter
+	async                  bool
+	retryAfter             time.Duration
+	report                 bool
+}
+
+// HandlerOption configures the http.Handler returned by Dispatcher.Handler.
+type HandlerOption func(*handlerOptions)
+
+// HandlerWithDispatchRequestOptions passes opts to DispatchRequest for every delivery.
+func HandlerWithDispatchRequestOptions(opts ...DispatchRequestOption) HandlerOption {
+	return func(o *handlerOptions) {
+		o.requestOpts = append(o.requestOpts, opts...)
+	}
+}
+
+// HandlerWithUnsupportedEventStatus sets the status code used when a delivery
+// carries an event type the dispatcher does not support. It defaults to
+// http.StatusAccepted so that GitLab does not disable the hook.
+func HandlerWithUnsupportedEventStatus(status int) HandlerOption {
+	return func(o *handlerOptions) {
+		o.unsupportedEventStatus = status
+	}
+}
+
+// HandlerWithErrorWriter replaces the default error response writer.
+func HandlerWithErrorWriter(writer ErrorWriter) HandlerOption {
+	return func(o *handlerOptions) {
+		o.errorWriter = writer
+	}
+}
+
+// HandlerWithSuccessWriter replaces the default success response writer.
+func HandlerWithSuccessWriter(writer SuccessWriter) HandlerOption {
+	return func(o *handlerOptions) {
+		o.successWriter = writer
+	}
+}
+
+// HandlerAsync acknowledges deliveries with 202 Accepted as soon as they are
+// validated and decoded, and dispatches them from the queue of Enqueue.
+// Deliveries that find the queue full are answered with 503 Service Unavailable.
+func HandlerAsync() HandlerOption {
+	return func(o *handlerOptions) {
+		o.async = true
+	}
+}
+
+// HandlerWithRetryAfter sets the Retry-After header sent with 503 Service
+// Unavailable responses. It defaults to 10 seconds.
+func HandlerWithRetryAfter(retryAfter time.Duration) HandlerOption {
+	return func(o *handlerOptions) {
+		o.retryAfter = retryAfter
+	}
+}
+
+// HandlerWithReport answers validated deliveries with their DispatchReport
+// as JSON, for debugging listeners from the GitLab webhook logs. Successful
+// deliveries are answered with 200 OK. It has no effect with HandlerAsync.
+func HandlerWithReport() HandlerOption {
+	return func(o *handlerOptions) {
+		o.report = true
+	}
+}
+
+// Handler returns an http.Handler that dispatches webhook deliveries and maps
+// dispatch errors to HTTP status codes.
+func (d *Dispatcher) Handler(opts ...HandlerOption) http.Handler {
+	o := &handlerOptions{
+		unsupportedEventStatus: http.StatusAccepted,
+		errorWriter:            writeError,
+		retryAfter:             defaultRetryAfter,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.successWriter == nil {
+		o.successWriter = writeSuccess
+		if o.async {
+			o.successWriter = writeAccepted
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			o.errorWriter(w, r, http.StatusMethodNotAllowed, ErrMethodNotAllowed)
+			return
+		}
+
+		var err error
+		switch {
+		case o.async:
+			err = d.EnqueueRequest(r, o.requestOpts...)
+		case o.report:
+			report := &DispatchReport{}
+			err = d.DispatchRequest(r, append(slices.Clip(o.requestOpts), DispatchRequestWithReport(report))...)
+			if report.recorded {
+				writeReport(w, o.statusCode(err), report)
+				return
+			}
+		default:
+			err = d.DispatchRequest(r, o.requestOpts...)
+		}
+		if err == nil {
+			o.successWriter(w, r)
+			return
+		}
+
+		status := o.statusCode(err)
+		if status >= http.StatusOK && status < http.StatusMultipleChoices {
+			w.WriteHeader(status)
+			return
+		}
+		if status == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", strconv.Itoa(int(o.retryAfter.Seconds())))
+		}
+		o.errorWriter(w, r, status, err)
+	})
+}
+
+// ServeHTTP implements http.Handler using the default Handler options.
+func (d *Dispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.Handler().ServeHTTP(w, r)
+}
+
+func (o *handlerOptions) statusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrInvalidToken),
+		errors.Is(err, ErrInvalidSignature),
+		errors.Is(err, ErrStaleDelivery):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrDuplicateDelivery):
+		return http.StatusOK
+	case errors.Is(err, ErrUnsupportedEvent):
+		return o.unsupportedEventStatus
+	case errors.Is(err, ErrInvalidPayload):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrQueueFull),
+		errors.Is(err, ErrDispatcherClosed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, _ *http.Request, status int, _ error) {
+	http.Error(w, http.StatusText(status), status)
+}
+
+func writeSuccess(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeReport(w http.ResponseWriter, status int, report *DispatchReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+func writeAccepted(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusAccepted)
+}
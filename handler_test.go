@@ -0,0 +1,154 @@
+package gitlabwebhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type failingPushListener struct{}
+
+func (failingPushListener) OnPush(context.Context, *gitlab.PushEvent) error {
+	return errors.New("downstream unavailable")
+}
+
+func TestDispatcher_Handler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		eventType  gitlab.EventType
+		body       []byte
+		token      string
+		listener   any
+		opts       []HandlerOption
+		wantStatus int
+	}{
+		{
+			name:       "dispatched",
+			method:     http.MethodPost,
+			eventType:  gitlab.EventTypePush,
+			body:       loadFixture("testdata/webhooks/push.json"),
+			listener:   &simpleTestListener{},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "wrong method",
+			method:     http.MethodGet,
+			eventType:  gitlab.EventTypePush,
+			listener:   &simpleTestListener{},
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "invalid token",
+			method:     http.MethodPost,
+			eventType:  gitlab.EventTypePush,
+			body:       loadFixture("testdata/webhooks/push.json"),
+			token:      "wrong-token",
+			listener:   &simpleTestListener{},
+			opts:       []HandlerOption{HandlerWithDispatchRequestOptions(DispatchRequestWithToken("secret"))},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "unsupported event",
+			method:     http.MethodPost,
+			eventType:  "Unknown Hook",
+			body:       []byte(`{}`),
+			listener:   &simpleTestListener{},
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "unsupported event with custom status",
+			method:     http.MethodPost,
+			eventType:  "Unknown Hook",
+			body:       []byte(`{}`),
+			listener:   &simpleTestListener{},
+			opts:       []HandlerOption{HandlerWithUnsupportedEventStatus(http.StatusBadRequest)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid payload",
+			method:     http.MethodPost,
+			eventType:  gitlab.EventTypePush,
+			body:       []byte(`{"object_kind":`),
+			listener:   &simpleTestListener{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid payload timestamp",
+			method:     http.MethodPost,
+			eventType:  gitlab.EventTypePush,
+			body:       []byte(`{"object_kind":"push","commits":[{"timestamp":"garbage"}]}`),
+			listener:   &simpleTestListener{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unsupported noteable type",
+			method:     http.MethodPost,
+			eventType:  gitlab.EventTypeNote,
+			body:       []byte(`{"object_kind":"note","object_attributes":{"noteable_type":"Epic"}}`),
+			listener:   IssueCommentListenerFunc(func(context.Context, *gitlab.IssueCommentEvent) error { return nil }),
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "listener error",
+			method:     http.MethodPost,
+			eventType:  gitlab.EventTypePush,
+			body:       loadFixture("testdata/webhooks/push.json"),
+			listener:   failingPushListener{},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:      "custom writers",
+			method:    http.MethodPost,
+			eventType: gitlab.EventTypePush,
+			body:      loadFixture("testdata/webhooks/push.json"),
+			listener:  &simpleTestListener{},
+			opts: []HandlerOption{
+				HandlerWithSuccessWriter(func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}),
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispatcher := NewDispatcher(RegisterListeners(tt.listener))
+
+			req := httptest.NewRequest(tt.method, "/webhook", bytes.NewReader(tt.body))
+			req.Header.Set("X-Gitlab-Event", string(tt.eventType))
+			if tt.token != "" {
+				req.Header.Set("X-Gitlab-Token", tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+			dispatcher.Handler(tt.opts...).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestDispatcher_HandlerErrorWriter(t *testing.T) {
+	var gotErr error
+	dispatcher := NewDispatcher(RegisterListeners(failingPushListener{}))
+	handler := dispatcher.Handler(HandlerWithErrorWriter(func(w http.ResponseWriter, _ *http.Request, status int, err error) {
+		gotErr = err
+		w.WriteHeader(status)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(loadFixture("testdata/webhooks/push.json")))
+	req.Header.Set("X-Gitlab-Event", string(gitlab.EventTypePush))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.EqualError(t, gotErr, "downstream unavailable")
+}
@@ -0,0 +1,273 @@
+package gitlabwebhook
+
+import (
+	"context"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// AccessRequestSystemListenerFunc is a function that implements AccessRequestSystemListener.
+type AccessRequestSystemListenerFunc func(ctx context.Context, event *AccessRequestSystemEvent) error
+
+func (f AccessRequestSystemListenerFunc) OnAccessRequestSystem(ctx context.Context, event *AccessRequestSystemEvent) error {
+	return f(ctx, event)
+}
+
+// AnyListenerFunc is a function that implements AnyListener.
+type AnyListenerFunc func(ctx context.Context, eventType gitlab.EventType, event any) error
+
+func (f AnyListenerFunc) OnEvent(ctx context.Context, eventType gitlab.EventType, event any) error {
+	return f(ctx, eventType, event)
+}
+
+// BuildListenerFunc is a function that implements BuildListener.
+type BuildListenerFunc func(ctx context.Context, event *gitlab.BuildEvent) error
+
+func (f BuildListenerFunc) OnBuild(ctx context.Context, event *gitlab.BuildEvent) error {
+	return f(ctx, event)
+}
+
+// CommitCommentListenerFunc is a function that implements CommitCommentListener.
+type CommitCommentListenerFunc func(ctx context.Context, event *gitlab.CommitCommentEvent) error
+
+func (f CommitCommentListenerFunc) OnCommitComment(ctx context.Context, event *gitlab.CommitCommentEvent) error {
+	return f(ctx, event)
+}
+
+// ConfidentialIssueListenerFunc is a function that implements ConfidentialIssueListener.
+type ConfidentialIssueListenerFunc func(ctx context.Context, event *gitlab.IssueEvent) error
+
+func (f ConfidentialIssueListenerFunc) OnConfidentialIssue(ctx context.Context, event *gitlab.IssueEvent) error {
+	return f(ctx, event)
+}
+
+// ConfidentialNoteListenerFunc is a function that implements ConfidentialNoteListener.
+type ConfidentialNoteListenerFunc func(ctx context.Context, event *gitlab.IssueCommentEvent) error
+
+func (f ConfidentialNoteListenerFunc) OnConfidentialNote(ctx context.Context, event *gitlab.IssueCommentEvent) error {
+	return f(ctx, event)
+}
+
+// DeploymentListenerFunc is a function that implements DeploymentListener.
+type DeploymentListenerFunc func(ctx context.Context, event *gitlab.DeploymentEvent) error
+
+func (f DeploymentListenerFunc) OnDeployment(ctx context.Context, event *gitlab.DeploymentEvent) error {
+	return f(ctx, event)
+}
+
+// EmojiListenerFunc is a function that implements EmojiListener.
+type EmojiListenerFunc func(ctx context.Context, event *EmojiEvent) error
+
+func (f EmojiListenerFunc) OnEmoji(ctx context.Context, event *EmojiEvent) error {
+	return f(ctx, event)
+}
+
+// FeatureFlagListenerFunc is a function that implements FeatureFlagListener.
+type FeatureFlagListenerFunc func(ctx context.Context, event *gitlab.FeatureFlagEvent) error
+
+func (f FeatureFlagListenerFunc) OnFeatureFlag(ctx context.Context, event *gitlab.FeatureFlagEvent) error {
+	return f(ctx, event)
+}
+
+// GroupResourceAccessTokenListenerFunc is a function that implements GroupResourceAccessTokenListener.
+type GroupResourceAccessTokenListenerFunc func(ctx context.Context, event *gitlab.GroupResourceAccessTokenEvent) error
+
+func (f GroupResourceAccessTokenListenerFunc) OnGroupResourceAccessToken(ctx context.Context, event *gitlab.GroupResourceAccessTokenEvent) error {
+	return f(ctx, event)
+}
+
+// GroupSystemListenerFunc is a function that implements GroupSystemListener.
+type GroupSystemListenerFunc func(ctx context.Context, event *gitlab.GroupSystemEvent) error
+
+func (f GroupSystemListenerFunc) OnGroupSystem(ctx context.Context, event *gitlab.GroupSystemEvent) error {
+	return f(ctx, event)
+}
+
+// IssueCommentListenerFunc is a function that implements IssueCommentListener.
+type IssueCommentListenerFunc func(ctx context.Context, event *gitlab.IssueCommentEvent) error
+
+func (f IssueCommentListenerFunc) OnIssueComment(ctx context.Context, event *gitlab.IssueCommentEvent) error {
+	return f(ctx, event)
+}
+
+// IssueListenerFunc is a function that implements IssueListener.
+type IssueListenerFunc func(ctx context.Context, event *gitlab.IssueEvent) error
+
+func (f IssueListenerFunc) OnIssue(ctx context.Context, event *gitlab.IssueEvent) error {
+	return f(ctx, event)
+}
+
+// JobListenerFunc is a function that implements JobListener.
+type JobListenerFunc func(ctx context.Context, event *gitlab.JobEvent) error
+
+func (f JobListenerFunc) OnJob(ctx context.Context, event *gitlab.JobEvent) error {
+	return f(ctx, event)
+}
+
+// KeySystemListenerFunc is a function that implements KeySystemListener.
+type KeySystemListenerFunc func(ctx context.Context, event *gitlab.KeySystemEvent) error
+
+func (f KeySystemListenerFunc) OnKeySystem(ctx context.Context, event *gitlab.KeySystemEvent) error {
+	return f(ctx, event)
+}
+
+// MemberAccessRequestListenerFunc is a function that implements MemberAccessRequestListener.
+type MemberAccessRequestListenerFunc func(ctx context.Context, event *gitlab.MemberEvent) error
+
+func (f MemberAccessRequestListenerFunc) OnMemberAccessRequest(ctx context.Context, event *gitlab.MemberEvent) error {
+	return f(ctx, event)
+}
+
+// MemberListenerFunc is a function that implements MemberListener.
+type MemberListenerFunc func(ctx context.Context, event *gitlab.MemberEvent) error
+
+func (f MemberListenerFunc) OnMember(ctx context.Context, event *gitlab.MemberEvent) error {
+	return f(ctx, event)
+}
+
+// MergeCommentListenerFunc is a function that implements MergeCommentListener.
+type MergeCommentListenerFunc func(ctx context.Context, event *gitlab.MergeCommentEvent) error
+
+func (f MergeCommentListenerFunc) OnMergeComment(ctx context.Context, event *gitlab.MergeCommentEvent) error {
+	return f(ctx, event)
+}
+
+// MergeListenerFunc is a function that implements MergeListener.
+type MergeListenerFunc func(ctx context.Context, event *gitlab.MergeEvent) error
+
+func (f MergeListenerFunc) OnMerge(ctx context.Context, event *gitlab.MergeEvent) error {
+	return f(ctx, event)
+}
+
+// MilestoneListenerFunc is a function that implements MilestoneListener.
+type MilestoneListenerFunc func(ctx context.Context, event *gitlab.MilestoneWebhookEvent) error
+
+func (f MilestoneListenerFunc) OnMilestone(ctx context.Context, event *gitlab.MilestoneWebhookEvent) error {
+	return f(ctx, event)
+}
+
+// PipelineListenerFunc is a function that implements PipelineListener.
+type PipelineListenerFunc func(ctx context.Context, event *gitlab.PipelineEvent) error
+
+func (f PipelineListenerFunc) OnPipeline(ctx context.Context, event *gitlab.PipelineEvent) error {
+	return f(ctx, event)
+}
+
+// ProjectListenerFunc is a function that implements ProjectListener.
+type ProjectListenerFunc func(ctx context.Context, event *gitlab.ProjectWebhookEvent) error
+
+func (f ProjectListenerFunc) OnProject(ctx context.Context, event *gitlab.ProjectWebhookEvent) error {
+	return f(ctx, event)
+}
+
+// ProjectResourceAccessTokenListenerFunc is a function that implements ProjectResourceAccessTokenListener.
+type ProjectResourceAccessTokenListenerFunc func(ctx context.Context, event *gitlab.ProjectResourceAccessTokenEvent) error
+
+func (f ProjectResourceAccessTokenListenerFunc) OnProjectResourceAccessToken(ctx context.Context, event *gitlab.ProjectResourceAccessTokenEvent) error {
+	return f(ctx, event)
+}
+
+// ProjectSystemListenerFunc is a function that implements ProjectSystemListener.
+type ProjectSystemListenerFunc func(ctx context.Context, event *gitlab.ProjectSystemEvent) error
+
+func (f ProjectSystemListenerFunc) OnProjectSystem(ctx context.Context, event *gitlab.ProjectSystemEvent) error {
+	return f(ctx, event)
+}
+
+// PushListenerFunc is a function that implements PushListener.
+type PushListenerFunc func(ctx context.Context, event *gitlab.PushEvent) error
+
+func (f PushListenerFunc) OnPush(ctx context.Context, event *gitlab.PushEvent) error {
+	return f(ctx, event)
+}
+
+// PushSystemListenerFunc is a function that implements PushSystemListener.
+type PushSystemListenerFunc func(ctx context.Context, event *gitlab.PushSystemEvent) error
+
+func (f PushSystemListenerFunc) OnPushSystem(ctx context.Context, event *gitlab.PushSystemEvent) error {
+	return f(ctx, event)
+}
+
+// ReleaseListenerFunc is a function that implements ReleaseListener.
+type ReleaseListenerFunc func(ctx context.Context, event *gitlab.ReleaseEvent) error
+
+func (f ReleaseListenerFunc) OnRelease(ctx context.Context, event *gitlab.ReleaseEvent) error {
+	return f(ctx, event)
+}
+
+// RepositoryUpdateSystemListenerFunc is a function that implements RepositoryUpdateSystemListener.
+type RepositoryUpdateSystemListenerFunc func(ctx context.Context, event *gitlab.RepositoryUpdateSystemEvent) error
+
+func (f RepositoryUpdateSystemListenerFunc) OnRepositoryUpdateSystem(ctx context.Context, event *gitlab.RepositoryUpdateSystemEvent) error {
+	return f(ctx, event)
+}
+
+// SnippetCommentListenerFunc is a function that implements SnippetCommentListener.
+type SnippetCommentListenerFunc func(ctx context.Context, event *gitlab.SnippetCommentEvent) error
+
+func (f SnippetCommentListenerFunc) OnSnippetComment(ctx context.Context, event *gitlab.SnippetCommentEvent) error {
+	return f(ctx, event)
+}
+
+// SubGroupListenerFunc is a function that implements SubGroupListener.
+type SubGroupListenerFunc func(ctx context.Context, event *gitlab.SubGroupEvent) error
+
+func (f SubGroupListenerFunc) OnSubGroup(ctx context.Context, event *gitlab.SubGroupEvent) error {
+	return f(ctx, event)
+}
+
+// TagListenerFunc is a function that implements TagListener.
+type TagListenerFunc func(ctx context.Context, event *gitlab.TagEvent) error
+
+func (f TagListenerFunc) OnTag(ctx context.Context, event *gitlab.TagEvent) error {
+	return f(ctx, event)
+}
+
+// TagPushSystemListenerFunc is a function that implements TagPushSystemListener.
+type TagPushSystemListenerFunc func(ctx context.Context, event *gitlab.TagPushSystemEvent) error
+
+func (f TagPushSystemListenerFunc) OnTagPushSystem(ctx context.Context, event *gitlab.TagPushSystemEvent) error {
+	return f(ctx, event)
+}
+
+// UserGroupSystemListenerFunc is a function that implements UserGroupSystemListener.
+type UserGroupSystemListenerFunc func(ctx context.Context, event *gitlab.UserGroupSystemEvent) error
+
+func (f UserGroupSystemListenerFunc) OnUserGroupSystem(ctx context.Context, event *gitlab.UserGroupSystemEvent) error {
+	return f(ctx, event)
+}
+
+// UserSystemListenerFunc is a function that implements UserSystemListener.
+type UserSystemListenerFunc func(ctx context.Context, event *gitlab.UserSystemEvent) error
+
+func (f UserSystemListenerFunc) OnUserSystem(ctx context.Context, event *gitlab.UserSystemEvent) error {
+	return f(ctx, event)
+}
+
+// UserTeamSystemListenerFunc is a function that implements UserTeamSystemListener.
+type UserTeamSystemListenerFunc func(ctx context.Context, event *gitlab.UserTeamSystemEvent) error
+
+func (f UserTeamSystemListenerFunc) OnUserTeamSystem(ctx context.Context, event *gitlab.UserTeamSystemEvent) error {
+	return f(ctx, event)
+}
+
+// VulnerabilityListenerFunc is a function that implements VulnerabilityListener.
+type VulnerabilityListenerFunc func(ctx context.Context, event *gitlab.VulnerabilityEvent) error
+
+func (f VulnerabilityListenerFunc) OnVulnerability(ctx context.Context, event *gitlab.VulnerabilityEvent) error {
+	return f(ctx, event)
+}
+
+// WikiPageListenerFunc is a function that implements WikiPageListener.
+type WikiPageListenerFunc func(ctx context.Context, event *gitlab.WikiPageEvent) error
+
+func (f WikiPageListenerFunc) OnWikiPage(ctx context.Context, event *gitlab.WikiPageEvent) error {
+	return f(ctx, event)
+}
+
+// WorkItemListenerFunc is a function that implements WorkItemListener.
+type WorkItemListenerFunc func(ctx context.Context, event *WorkItemEvent) error
+
+func (f WorkItemListenerFunc) OnWorkItem(ctx context.Context, event *WorkItemEvent) error {
+	return f(ctx, event)
+}
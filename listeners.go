@@ -6,6 +6,17 @@ import (
 	gitlab "gitlab.com/gitlab-org/api/client-go"
 )
 
+type AccessRequestSystemListener interface {
+	OnAccessRequestSystem(ctx context.Context, event *AccessRequestSystemEvent) error
+}
+
+// AnyListener receives every dispatched event together with its event type,
+// in addition to the typed listeners. Events the dispatcher does not support
+// are passed as json.RawMessage.
+type AnyListener interface {
+	OnEvent(ctx context.Context, eventType gitlab.EventType, event any) error
+}
+
 type BuildListener interface {
 	OnBuild(ctx context.Context, event *gitlab.BuildEvent) error
 }
@@ -14,6 +25,20 @@ type CommitCommentListener interface {
 	OnCommitComment(ctx context.Context, event *gitlab.CommitCommentEvent) error
 }
 
+// ConfidentialIssueListener receives Confidential Issue Hook deliveries, which
+// are passed to IssueListener too unless WithConfidentialListenersOnly is used.
+type ConfidentialIssueListener interface {
+	OnConfidentialIssue(ctx context.Context, event *gitlab.IssueEvent) error
+}
+
+// ConfidentialNoteListener receives Confidential Note Hook deliveries on issues,
+// which are passed to IssueCommentListener too unless
+// WithConfidentialListenersOnly is used. Confidential notes on other noteables
+// reach their comment listeners, where IsConfidential reports them.
+type ConfidentialNoteListener interface {
+	OnConfidentialNote(ctx context.Context, event *gitlab.IssueCommentEvent) error
+}
+
 type DeploymentListener interface {
 	OnDeployment(ctx context.Context, event *gitlab.DeploymentEvent) error
 }
@@ -30,6 +55,10 @@ type GroupResourceAccessTokenListener interface {
 	OnGroupResourceAccessToken(ctx context.Context, event *gitlab.GroupResourceAccessTokenEvent) error
 }
 
+type GroupSystemListener interface {
+	OnGroupSystem(ctx context.Context, event *gitlab.GroupSystemEvent) error
+}
+
 type IssueCommentListener interface {
 	OnIssueComment(ctx context.Context, event *gitlab.IssueCommentEvent) error
 }
@@ -42,6 +71,17 @@ type JobListener interface {
 	OnJob(ctx context.Context, event *gitlab.JobEvent) error
 }
 
+type KeySystemListener interface {
+	OnKeySystem(ctx context.Context, event *gitlab.KeySystemEvent) error
+}
+
+// MemberAccessRequestListener receives the member events of users requesting
+// access to a group, or revoking such a request. These events are delivered
+// to MemberListener as well.
+type MemberAccessRequestListener interface {
+	OnMemberAccessRequest(ctx context.Context, event *gitlab.MemberEvent) error
+}
+
 type MemberListener interface {
 	OnMember(ctx context.Context, event *gitlab.MemberEvent) error
 }
@@ -54,22 +94,42 @@ type MergeListener interface {
 	OnMerge(ctx context.Context, event *gitlab.MergeEvent) error
 }
 
+type MilestoneListener interface {
+	OnMilestone(ctx context.Context, event *gitlab.MilestoneWebhookEvent) error
+}
+
 type PipelineListener interface {
 	OnPipeline(ctx context.Context, event *gitlab.PipelineEvent) error
 }
 
+type ProjectListener interface {
+	OnProject(ctx context.Context, event *gitlab.ProjectWebhookEvent) error
+}
+
 type ProjectResourceAccessTokenListener interface {
 	OnProjectResourceAccessToken(ctx context.Context, event *gitlab.ProjectResourceAccessTokenEvent) error
 }
 
+type ProjectSystemListener interface {
+	OnProjectSystem(ctx context.Context, event *gitlab.ProjectSystemEvent) error
+}
+
 type PushListener interface {
 	OnPush(ctx context.Context, event *gitlab.PushEvent) error
 }
 
+type PushSystemListener interface {
+	OnPushSystem(ctx context.Context, event *gitlab.PushSystemEvent) error
+}
+
 type ReleaseListener interface {
 	OnRelease(ctx context.Context, event *gitlab.ReleaseEvent) error
 }
 
+type RepositoryUpdateSystemListener interface {
+	OnRepositoryUpdateSystem(ctx context.Context, event *gitlab.RepositoryUpdateSystemEvent) error
+}
+
 type SnippetCommentListener interface {
 	OnSnippetComment(ctx context.Context, event *gitlab.SnippetCommentEvent) error
 }
@@ -82,6 +142,33 @@ type TagListener interface {
 	OnTag(ctx context.Context, event *gitlab.TagEvent) error
 }
 
+type TagPushSystemListener interface {
+	OnTagPushSystem(ctx context.Context, event *gitlab.TagPushSystemEvent) error
+}
+
+type UserGroupSystemListener interface {
+	OnUserGroupSystem(ctx context.Context, event *gitlab.UserGroupSystemEvent) error
+}
+
+type UserSystemListener interface {
+	OnUserSystem(ctx context.Context, event *gitlab.UserSystemEvent) error
+}
+
+type UserTeamSystemListener interface {
+	OnUserTeamSystem(ctx context.Context, event *gitlab.UserTeamSystemEvent) error
+}
+
+type VulnerabilityListener interface {
+	OnVulnerability(ctx context.Context, event *gitlab.VulnerabilityEvent) error
+}
+
 type WikiPageListener interface {
 	OnWikiPage(ctx context.Context, event *gitlab.WikiPageEvent) error
 }
+
+// WorkItemListener receives Issue Hook deliveries with object_kind work_item,
+// such as tasks, epics, objectives and key results. These are not passed to
+// IssueListener.
+type WorkItemListener interface {
+	OnWorkItem(ctx context.Context, event *WorkItemEvent) error
+}
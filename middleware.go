@@ -0,0 +1,43 @@
+package gitlabwebhook
+
+import (
+	"context"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// InvokeFunc invokes a listener with an event.
+type InvokeFunc func(ctx context.Context, eventType gitlab.EventType, event any) error
+
+// Middleware wraps the invocation of listener. It must call next with an
+// event of the same type it received.
+type Middleware func(listener any, next InvokeFunc) InvokeFunc
+
+// WithMiddleware adds middleware to the dispatcher, see Dispatcher.Use.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(d *Dispatcher) {
+		d.Use(middleware...)
+	}
+}
+
+// Use adds middleware around every listener invocation. Middleware runs in
+// the order it was added, the first being the outermost. It applies to
+// invocations that start after Use returns.
+func (d *Dispatcher) Use(middleware ...Middleware) {
+	d.update(func(r *registry) {
+		r.middleware = append(r.middleware, middleware...)
+	})
+}
+
+func callListener(ctx context.Context, d *Dispatcher, eventType gitlab.EventType, r registration, event any) error {
+	middleware := d.loadRegistry().middleware
+	if len(middleware) == 0 {
+		return r.invoke(ctx, eventType, event)
+	}
+
+	next := r.invoke
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](r.listener, next)
+	}
+	return next(ctx, eventType, event)
+}
@@ -0,0 +1,81 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestDispatcher_Use(t *testing.T) {
+	listener := &countingPushListener{}
+	var calls []string
+
+	record := func(name string) Middleware {
+		return func(l any, next InvokeFunc) InvokeFunc {
+			return func(ctx context.Context, eventType gitlab.EventType, event any) error {
+				assert.Same(t, listener, l)
+				assert.Equal(t, gitlab.EventTypePush, eventType)
+				assert.IsType(t, &gitlab.PushEvent{}, event)
+
+				calls = append(calls, name+" before")
+				err := next(ctx, eventType, event)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+
+	dispatcher := NewDispatcher(WithMiddleware(record("outer")), RegisterListeners(listener))
+	dispatcher.Use(record("inner"))
+
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{}))
+	assert.Equal(t, int64(1), listener.calls.Load())
+	assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, calls)
+}
+
+func TestDispatcher_UseShortCircuit(t *testing.T) {
+	errDenied := errors.New("denied")
+	listener := &countingPushListener{}
+	dispatcher := NewDispatcher(
+		RegisterListeners(listener),
+		WithMiddleware(func(any, InvokeFunc) InvokeFunc {
+			return func(context.Context, gitlab.EventType, any) error {
+				return errDenied
+			}
+		}),
+	)
+
+	assert.ErrorIs(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{}), errDenied)
+	assert.Zero(t, listener.calls.Load())
+}
+
+func TestDispatcher_UseWhileDispatching(t *testing.T) {
+	const iterations = 100
+
+	listener := &countingPushListener{}
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+	passThrough := func(_ any, next InvokeFunc) InvokeFunc { return next }
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for range iterations {
+			dispatcher.Use(passThrough)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range iterations {
+			assert.NoError(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{}))
+		}
+	}()
+	wg.Wait()
+
+	assert.Equal(t, int64(iterations), listener.calls.Load())
+	assert.Len(t, dispatcher.loadRegistry().middleware, iterations)
+}
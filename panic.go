@@ -0,0 +1,45 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// ListenerPanicError is returned in place of the error of a listener that panicked.
+type ListenerPanicError struct {
+	EventType    gitlab.EventType
+	ListenerType string
+	// Value is the value the listener panicked with.
+	Value any
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *ListenerPanicError) Error() string {
+	return fmt.Sprintf("gitlab-webhook: listener %s panicked handling %s: %v", e.ListenerType, e.EventType, e.Value)
+}
+
+// Unwrap returns Value if the listener panicked with an error.
+func (e *ListenerPanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+func invokeListener(ctx context.Context, d *Dispatcher, eventType gitlab.EventType, r registration, event any) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &ListenerPanicError{
+				EventType:    eventType,
+				ListenerType: fmt.Sprintf("%T", r.listener),
+				Value:        v,
+				Stack:        debug.Stack(),
+			}
+		}
+	}()
+	return callListener(ctx, d, eventType, r, event)
+}
@@ -0,0 +1,50 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type panickingPushListener struct {
+	value any
+}
+
+func (p *panickingPushListener) OnPush(context.Context, *gitlab.PushEvent) error {
+	panic(p.value)
+}
+
+func TestDispatcher_ListenerPanic(t *testing.T) {
+	survivor := &countingPushListener{}
+	dispatcher := NewDispatcher(RegisterListeners(
+		&panickingPushListener{value: "boom"},
+		survivor,
+		&panickingPushListener{value: io.ErrUnexpectedEOF},
+	))
+
+	err := dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypePush, loadFixture("testdata/webhooks/push.json"))
+	require.Error(t, err)
+	assert.Equal(t, int64(1), survivor.calls.Load())
+
+	var panicErr *ListenerPanicError
+	require.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, gitlab.EventTypePush, panicErr.EventType)
+	assert.Equal(t, "*gitlabwebhook.panickingPushListener", panicErr.ListenerType)
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "OnPush")
+
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestDispatcher_LoneListenerPanic(t *testing.T) {
+	dispatcher := NewDispatcher(RegisterListeners(&panickingPushListener{value: "boom"}))
+
+	err := dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{})
+
+	var panicErr *ListenerPanicError
+	assert.ErrorAs(t, err, &panicErr)
+}
@@ -0,0 +1,112 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// DispatchPayload dispatches a webhook payload whose event type is not known,
+// such as a stored delivery being replayed, inferring the type from the
+// object_kind, event_name and event_type fields of the payload.
+//
+// Payloads are attributed to project and group hooks when they are
+// indistinguishable from system hooks, and job events to the Job Hook rather
+// than the legacy Build Hook. A payload whose type cannot be inferred is
+// reported as ErrUnsupportedEvent.
+func (d *Dispatcher) DispatchPayload(ctx context.Context, payload []byte) error {
+	eventType, err := d.detectEventType(payload)
+	if err != nil {
+		return err
+	}
+	return d.DispatchWebhook(ctx, eventType, payload)
+}
+
+type payloadHeader struct {
+	ObjectKind string `json:"object_kind"`
+	EventName  string `json:"event_name"`
+	EventType  string `json:"event_type"`
+
+	// fields only group and project hooks send along with an event_name
+	// that system hooks use as well
+	GroupPlan          json.RawMessage `json:"group_plan"`
+	ProjectNamespaceID json.RawMessage `json:"project_namespace_id"`
+}
+
+func (d *Dispatcher) detectEventType(payload []byte) (gitlab.EventType, error) {
+	var h payloadHeader
+	if err := json.Unmarshal(payload, &h); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidPayload, err)
+	}
+
+	switch h.EventType {
+	case "confidential_issue":
+		return gitlab.EventConfidentialIssue, nil
+	case "confidential_note":
+		return gitlab.EventConfidentialNote, nil
+	}
+
+	if h.ObjectKind != "" {
+		if eventType, ok := objectKindEventTypes[h.ObjectKind]; ok {
+			return eventType, nil
+		}
+		if eventType, ok := d.registeredObjectKind(h.ObjectKind); ok {
+			return eventType, nil
+		}
+		return "", fmt.Errorf("%w: object kind %q", ErrUnsupportedEvent, h.ObjectKind)
+	}
+
+	switch h.EventName {
+	case "":
+		return "", fmt.Errorf("%w: payload has no object_kind or event_name", ErrUnsupportedEvent)
+	case "subgroup_create", "subgroup_destroy":
+		return gitlab.EventTypeSubGroup, nil
+	case "project_create", "project_destroy":
+		if h.ProjectNamespaceID != nil {
+			return gitlab.EventTypeProject, nil
+		}
+	case "user_add_to_group", "user_remove_from_group", "user_update_for_group",
+		"user_access_request_to_group", "user_access_request_revoked_for_group":
+		if h.GroupPlan != nil {
+			return gitlab.EventTypeMember, nil
+		}
+	}
+	return gitlab.EventTypeSystemHook, nil
+}
+
+var objectKindEventTypes = map[string]gitlab.EventType{
+	"access_token":     gitlab.EventTypeResourceAccessToken,
+	"build":            gitlab.EventTypeJob,
+	"deployment":       gitlab.EventTypeDeployment,
+	"emoji":            gitlab.EventTypeEmoji,
+	"feature_flag":     gitlab.EventTypeFeatureFlag,
+	"issue":            gitlab.EventTypeIssue,
+	"merge_request":    gitlab.EventTypeMergeRequest,
+	"milestone":        gitlab.EventTypeMilestone,
+	"note":             gitlab.EventTypeNote,
+	"pipeline":         gitlab.EventTypePipeline,
+	"push":             gitlab.EventTypePush,
+	"release":          gitlab.EventTypeRelease,
+	"tag_push":         gitlab.EventTypeTagPush,
+	"vulnerability":    gitlab.EventTypeVulnerability,
+	"wiki_page":        gitlab.EventTypeWikiPage,
+	objectKindWorkItem: gitlab.EventTypeIssue,
+}
+
+// registeredObjectKind returns the event type a decoder was registered for
+// with EventTypeWithObjectKind, if only one was.
+func (d *Dispatcher) registeredObjectKind(objectKind string) (gitlab.EventType, bool) {
+	var eventType gitlab.EventType
+	for key := range d.loadRegistry().decoders {
+		if key.objectKind != objectKind {
+			continue
+		}
+		if eventType != "" && eventType != key.eventType {
+			return "", false
+		}
+		eventType = key.eventType
+	}
+	return eventType, eventType != ""
+}
@@ -0,0 +1,127 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestDispatcher_DispatchPayload(t *testing.T) {
+	tests := map[string]struct {
+		eventType gitlab.EventType
+		event     any
+	}{
+		"build":                         {gitlab.EventTypeJob, &gitlab.JobEvent{}},
+		"confidential_issue":            {gitlab.EventConfidentialIssue, &gitlab.IssueEvent{}},
+		"confidential_note":             {gitlab.EventConfidentialNote, &gitlab.IssueCommentEvent{}},
+		"deployment":                    {gitlab.EventTypeDeployment, &gitlab.DeploymentEvent{}},
+		"emoji":                         {gitlab.EventTypeEmoji, &EmojiEvent{}},
+		"emoji2":                        {gitlab.EventTypeEmoji, &EmojiEvent{}},
+		"feature_flag":                  {gitlab.EventTypeFeatureFlag, &gitlab.FeatureFlagEvent{}},
+		"group_merge_request":           {gitlab.EventTypeMergeRequest, &gitlab.MergeEvent{}},
+		"issue":                         {gitlab.EventTypeIssue, &gitlab.IssueEvent{}},
+		"job":                           {gitlab.EventTypeJob, &gitlab.JobEvent{}},
+		"member":                        {gitlab.EventTypeMember, &gitlab.MemberEvent{}},
+		"member_access_request":         {gitlab.EventTypeMember, &gitlab.MemberEvent{}},
+		"merge_request":                 {gitlab.EventTypeMergeRequest, &gitlab.MergeEvent{}},
+		"milestone":                     {gitlab.EventTypeMilestone, &gitlab.MilestoneWebhookEvent{}},
+		"milestone_group":               {gitlab.EventTypeMilestone, &gitlab.MilestoneWebhookEvent{}},
+		"note_commit":                   {gitlab.EventTypeNote, &gitlab.CommitCommentEvent{}},
+		"note_issue":                    {gitlab.EventTypeNote, &gitlab.IssueCommentEvent{}},
+		"note_merge_request":            {gitlab.EventTypeNote, &gitlab.MergeCommentEvent{}},
+		"note_snippet":                  {gitlab.EventTypeNote, &gitlab.SnippetCommentEvent{}},
+		"pipeline":                      {gitlab.EventTypePipeline, &gitlab.PipelineEvent{}},
+		"project":                       {gitlab.EventTypeProject, &gitlab.ProjectWebhookEvent{}},
+		"push":                          {gitlab.EventTypePush, &gitlab.PushEvent{}},
+		"release":                       {gitlab.EventTypeRelease, &gitlab.ReleaseEvent{}},
+		"resource_access_token_group":   {gitlab.EventTypeResourceAccessToken, &gitlab.GroupResourceAccessTokenEvent{}},
+		"resource_access_token_project": {gitlab.EventTypeResourceAccessToken, &gitlab.ProjectResourceAccessTokenEvent{}},
+		"service_merge_request":         {gitlab.EventTypeMergeRequest, &gitlab.MergeEvent{}},
+		"subgroup":                      {gitlab.EventTypeSubGroup, &gitlab.SubGroupEvent{}},
+		"tag_push":                      {gitlab.EventTypeTagPush, &gitlab.TagEvent{}},
+		"vulnerability":                 {gitlab.EventTypeVulnerability, &gitlab.VulnerabilityEvent{}},
+		"wiki_page":                     {gitlab.EventTypeWikiPage, &gitlab.WikiPageEvent{}},
+		"work_item":                     {gitlab.EventTypeIssue, &WorkItemEvent{}},
+	}
+
+	fixtures, err := filepath.Glob("testdata/webhooks/*.json")
+	require.NoError(t, err)
+	require.Len(t, fixtures, len(tests))
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".json")
+		t.Run(name, func(t *testing.T) {
+			tt, ok := tests[name]
+			require.True(t, ok, "no expectation for fixture %s", fixture)
+
+			var (
+				eventType gitlab.EventType
+				event     any
+			)
+			dispatcher := NewDispatcher()
+			dispatcher.RegisterAnyListener(AnyListenerFunc(func(_ context.Context, et gitlab.EventType, e any) error {
+				eventType, event = et, e
+				return nil
+			}))
+
+			require.NoError(t, dispatcher.DispatchPayload(context.Background(), loadFixture(fixture)))
+			assert.Equal(t, tt.eventType, eventType)
+			assert.IsType(t, tt.event, event)
+		})
+	}
+}
+
+func TestDispatcher_DispatchPayloadSystemHook(t *testing.T) {
+	tests := []struct {
+		fixture string
+		event   any
+	}{
+		{"group_create", &gitlab.GroupSystemEvent{}},
+		{"project_create", &gitlab.ProjectSystemEvent{}},
+		{"push", &gitlab.PushSystemEvent{}},
+		{"user_add_to_group", &gitlab.UserGroupSystemEvent{}},
+		{"user_access_request_to_group", &AccessRequestSystemEvent{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			var (
+				eventType gitlab.EventType
+				event     any
+			)
+			dispatcher := NewDispatcher()
+			dispatcher.RegisterAnyListener(AnyListenerFunc(func(_ context.Context, et gitlab.EventType, e any) error {
+				eventType, event = et, e
+				return nil
+			}))
+
+			require.NoError(t, dispatcher.DispatchPayload(context.Background(), loadFixture("testdata/systemhooks/"+tt.fixture+".json"))) //nolint:lll
+			assert.Equal(t, gitlab.EventTypeSystemHook, eventType)
+			assert.IsType(t, tt.event, event)
+		})
+	}
+}
+
+func TestDispatcher_DispatchPayloadUndetected(t *testing.T) {
+	dispatcher := NewDispatcher()
+	dispatcher.RegisterAnyListener(AnyListenerFunc(func(context.Context, gitlab.EventType, any) error { return nil }))
+
+	ctx := context.Background()
+	assert.ErrorIs(t, dispatcher.DispatchPayload(ctx, []byte(`{`)), ErrInvalidPayload)
+	assert.ErrorIs(t, dispatcher.DispatchPayload(ctx, []byte(`{}`)), ErrUnsupportedEvent)
+	assert.ErrorIs(t, dispatcher.DispatchPayload(ctx, []byte(`{"object_kind":"audit"}`)), ErrUnsupportedEvent)
+
+	var audits int
+	dispatcher.RegisterEventType("Audit Hook", decodeJSON[auditEvent], EventTypeWithObjectKind("audit"))
+	On(dispatcher, func(context.Context, *auditEvent) error {
+		audits++
+		return nil
+	})
+	require.NoError(t, dispatcher.DispatchPayload(ctx, []byte(`{"object_kind":"audit"}`)))
+	assert.Equal(t, 1, audits)
+}
@@ -0,0 +1,64 @@
+package gitlabwebhook
+
+import "sync"
+
+// workerPool runs listener invocations on a fixed set of goroutines that are
+// started on first use.
+type workerPool struct {
+	size  int
+	once  sync.Once
+	tasks chan func()
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newWorkerPool(size int) *workerPool {
+	return &workerPool{
+		size:  size,
+		tasks: make(chan func()),
+	}
+}
+
+func (p *workerPool) start() {
+	for range p.size {
+		go func() {
+			for task := range p.tasks {
+				task()
+			}
+		}()
+	}
+}
+
+// submit hands task to an idle worker. When every worker is busy, or the pool
+// is closed, the task runs on the calling goroutine instead, which applies
+// backpressure to the caller and avoids deadlocks when a listener dispatches
+// events itself.
+func (p *workerPool) submit(task func()) {
+	p.once.Do(p.start)
+
+	p.mu.RLock()
+	handed := false
+	if !p.closed {
+		select {
+		case p.tasks <- task:
+			handed = true
+		default:
+		}
+	}
+	p.mu.RUnlock()
+
+	if !handed {
+		task()
+	}
+}
+
+// close stops the workers once they finish their current task.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+}
@@ -0,0 +1,308 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// listenerVariant tells apart listeners of the same event type that receive
+// different deliveries, such as IssueListener and ConfidentialIssueListener.
+type listenerVariant uint8
+
+const (
+	variantDefault listenerVariant = iota
+	variantConfidential
+	variantAccessRequest
+)
+
+var defaultVariants = []listenerVariant{variantDefault}
+
+// listenerKey identifies the listeners of an event type. The zero key holds
+// the listeners of every event.
+type listenerKey struct {
+	eventType reflect.Type
+	variant   listenerVariant
+}
+
+type registration struct {
+	id       uint64
+	listener any
+	invoke   InvokeFunc
+	filters  []eventFilter
+}
+
+type subscription struct {
+	key listenerKey
+	registration
+}
+
+// Unsubscribe removes the listeners it was returned for. Listeners already
+// invoked for an event in flight still complete. Calling it more than once
+// has no effect.
+type Unsubscribe func()
+
+// registry holds the listeners and decoders of a Dispatcher. It is never
+// modified once published, so dispatching reads it without locking while
+// updates replace it with a modified copy.
+type registry struct {
+	listeners   map[listenerKey][]registration
+	decoders    map[decoderKey]DecodeFunc
+	objectKinds map[gitlab.EventType]bool
+
+	// hookListeners counts the listeners of each webhook event type, and
+	// untypedListeners those of event types defined outside this package.
+	hookListeners    map[gitlab.EventType]int
+	untypedListeners int
+
+	middleware []Middleware
+}
+
+var emptyRegistry = &registry{}
+
+func (r *registry) clone() *registry {
+	return &registry{
+		listeners:   maps.Clone(r.listeners),
+		decoders:    maps.Clone(r.decoders),
+		objectKinds: maps.Clone(r.objectKinds),
+
+		hookListeners:    maps.Clone(r.hookListeners),
+		untypedListeners: r.untypedListeners,
+
+		middleware: slices.Clip(r.middleware),
+	}
+}
+
+// count adds n to the listener counts of the event type of key.
+func (r *registry) count(key listenerKey, n int) {
+	if key.eventType == nil {
+		return
+	}
+	eventTypes := hookEventTypes(key.eventType)
+	if len(eventTypes) == 0 {
+		r.untypedListeners += n
+		return
+	}
+	if r.hookListeners == nil {
+		r.hookListeners = make(map[gitlab.EventType]int)
+	}
+	for _, eventType := range eventTypes {
+		r.hookListeners[eventType] += n
+		if r.hookListeners[eventType] == 0 {
+			delete(r.hookListeners, eventType)
+		}
+	}
+}
+
+// hasDecoder reports whether a registered or built-in decoder decodes
+// deliveries of eventType.
+func (r *registry) hasDecoder(eventType gitlab.EventType) bool {
+	if r.objectKinds[eventType] || builtinObjectKinds[eventType] {
+		return true
+	}
+	key := decoderKey{eventType: eventType}
+	if _, ok := r.decoders[key]; ok {
+		return true
+	}
+	_, ok := builtinDecoders[key]
+	return ok
+}
+
+func (d *Dispatcher) loadRegistry() *registry {
+	if r := d.registry.Load(); r != nil {
+		return r
+	}
+	return emptyRegistry
+}
+
+// update publishes a copy of the registry modified by fn.
+func (d *Dispatcher) update(fn func(r *registry)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	r := d.loadRegistry().clone()
+	fn(r)
+	d.registry.Store(r)
+}
+
+// On registers fn for events of type E. E can be any type passed to Dispatch,
+// including event types defined outside this package.
+func On[E any](d *Dispatcher, fn func(ctx context.Context, event E) error, opts ...ListenerOption) Unsubscribe {
+	return register(d, variantDefault, func(fn func(context.Context, E) error, ctx context.Context, event E) error {
+		return fn(ctx, event)
+	}, fn, opts...)
+}
+
+func register[E any, L any](d *Dispatcher, variant listenerVariant, handler func(L, context.Context, E) error, listener L, opts ...ListenerOption) Unsubscribe { //nolint:lll
+	return d.subscribe(newSubscription(variant, handler, listener, newListenerOptions(opts)))
+}
+
+func newSubscription[E any, L any](variant listenerVariant, handler func(L, context.Context, E) error, l L, o listenerOptions) subscription { //nolint:lll
+	return subscription{
+		key: listenerKey{eventType: reflect.TypeFor[E](), variant: variant},
+		registration: registration{
+			listener: l,
+			invoke: func(ctx context.Context, _ gitlab.EventType, event any) error {
+				return handler(l, ctx, event.(E))
+			},
+			filters: o.filters,
+		},
+	}
+}
+
+func anySubscription(l AnyListener, o listenerOptions) subscription {
+	return subscription{registration: registration{listener: l, invoke: l.OnEvent, filters: o.filters}}
+}
+
+func (d *Dispatcher) subscribe(subs ...subscription) Unsubscribe {
+	d.update(func(r *registry) {
+		if r.listeners == nil {
+			r.listeners = make(map[listenerKey][]registration)
+		}
+		for i := range subs {
+			d.nextID++
+			subs[i].id = d.nextID
+			// clip so the append never writes into an array a published
+			// registry still reads
+			r.listeners[subs[i].key] = append(slices.Clip(r.listeners[subs[i].key]), subs[i].registration)
+			r.count(subs[i].key, 1)
+		}
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.unsubscribe(subs)
+		})
+	}
+}
+
+func (d *Dispatcher) unsubscribe(subs []subscription) {
+	d.update(func(r *registry) {
+		for _, sub := range subs {
+			listeners := slices.DeleteFunc(slices.Clone(r.listeners[sub.key]), func(reg registration) bool {
+				return reg.id == sub.id
+			})
+			r.count(sub.key, len(listeners)-len(r.listeners[sub.key]))
+			if len(listeners) == 0 {
+				delete(r.listeners, sub.key)
+				continue
+			}
+			r.listeners[sub.key] = listeners
+		}
+	})
+}
+
+// listenersFor returns the listeners event is dispatched to, or false if it
+// has no event type and no listeners were registered for its type with On.
+func (d *Dispatcher) listenersFor(ctx context.Context, eventType gitlab.EventType, event any) (context.Context, []registration, bool) { //nolint:lll
+	r := d.loadRegistry()
+	typ := reflect.TypeOf(event)
+	catchAll := r.listeners[listenerKey{}]
+	if _, ok := r.listeners[listenerKey{eventType: typ}]; !ok && eventType == "" && len(catchAll) == 0 {
+		return ctx, nil, false
+	}
+
+	ctx, variants := d.variants(ctx, event)
+	if len(variants) == 1 && len(catchAll) == 0 {
+		return ctx, r.listeners[listenerKey{eventType: typ, variant: variants[0]}], true
+	}
+
+	var listeners []registration
+	for _, variant := range variants {
+		listeners = append(listeners, r.listeners[listenerKey{eventType: typ, variant: variant}]...)
+	}
+	return ctx, append(listeners, catchAll...), true
+}
+
+// variants returns which listeners of its type event is dispatched to.
+func (d *Dispatcher) variants(ctx context.Context, event any) (context.Context, []listenerVariant) {
+	confidential := IsConfidential(ctx)
+	switch e := event.(type) {
+	case *gitlab.IssueEvent:
+		confidential = confidential || e.ObjectAttributes.Confidential
+	case *gitlab.IssueCommentEvent:
+		confidential = confidential || e.Issue.Confidential
+	case *gitlab.MemberEvent:
+		if isAccessRequest(e.EventName) {
+			return ctx, []listenerVariant{variantDefault, variantAccessRequest}
+		}
+		return ctx, defaultVariants
+	default:
+		return ctx, defaultVariants
+	}
+
+	if !confidential {
+		return ctx, defaultVariants
+	}
+	ctx = withConfidential(ctx)
+	if d.confidentialListenersOnly {
+		return ctx, []listenerVariant{variantConfidential}
+	}
+	return ctx, []listenerVariant{variantConfidential, variantDefault}
+}
+
+// listenerBindings subscribe a listener passed to RegisterListeners for every
+// listener interface it implements.
+var listenerBindings = []func(listener any, o listenerOptions) (subscription, bool){
+	bind(variantDefault, AccessRequestSystemListener.OnAccessRequestSystem),
+	bindAny,
+	bind(variantDefault, BuildListener.OnBuild),
+	bind(variantDefault, CommitCommentListener.OnCommitComment),
+	bind(variantConfidential, ConfidentialIssueListener.OnConfidentialIssue),
+	bind(variantConfidential, ConfidentialNoteListener.OnConfidentialNote),
+	bind(variantDefault, DeploymentListener.OnDeployment),
+	bind(variantDefault, EmojiListener.OnEmoji),
+	bind(variantDefault, FeatureFlagListener.OnFeatureFlag),
+	bind(variantDefault, GroupResourceAccessTokenListener.OnGroupResourceAccessToken),
+	bind(variantDefault, GroupSystemListener.OnGroupSystem),
+	bind(variantDefault, IssueCommentListener.OnIssueComment),
+	bind(variantDefault, IssueListener.OnIssue),
+	bind(variantDefault, JobListener.OnJob),
+	bind(variantDefault, KeySystemListener.OnKeySystem),
+	bind(variantAccessRequest, MemberAccessRequestListener.OnMemberAccessRequest),
+	bind(variantDefault, MemberListener.OnMember),
+	bind(variantDefault, MergeCommentListener.OnMergeComment),
+	bind(variantDefault, MergeListener.OnMerge),
+	bind(variantDefault, MilestoneListener.OnMilestone),
+	bind(variantDefault, PipelineListener.OnPipeline),
+	bind(variantDefault, ProjectListener.OnProject),
+	bind(variantDefault, ProjectResourceAccessTokenListener.OnProjectResourceAccessToken),
+	bind(variantDefault, ProjectSystemListener.OnProjectSystem),
+	bind(variantDefault, PushListener.OnPush),
+	bind(variantDefault, PushSystemListener.OnPushSystem),
+	bind(variantDefault, ReleaseListener.OnRelease),
+	bind(variantDefault, RepositoryUpdateSystemListener.OnRepositoryUpdateSystem),
+	bind(variantDefault, SnippetCommentListener.OnSnippetComment),
+	bind(variantDefault, SubGroupListener.OnSubGroup),
+	bind(variantDefault, TagListener.OnTag),
+	bind(variantDefault, TagPushSystemListener.OnTagPushSystem),
+	bind(variantDefault, UserGroupSystemListener.OnUserGroupSystem),
+	bind(variantDefault, UserSystemListener.OnUserSystem),
+	bind(variantDefault, UserTeamSystemListener.OnUserTeamSystem),
+	bind(variantDefault, VulnerabilityListener.OnVulnerability),
+	bind(variantDefault, WikiPageListener.OnWikiPage),
+	bind(variantDefault, WorkItemListener.OnWorkItem),
+}
+
+func bind[E any, L any](variant listenerVariant, handler func(L, context.Context, E) error) func(any, listenerOptions) (subscription, bool) { //nolint:lll
+	return func(listener any, o listenerOptions) (subscription, bool) {
+		l, ok := listener.(L)
+		if !ok {
+			return subscription{}, false
+		}
+		return newSubscription(variant, handler, l, o), true
+	}
+}
+
+func bindAny(listener any, o listenerOptions) (subscription, bool) {
+	l, ok := listener.(AnyListener)
+	if !ok {
+		return subscription{}, false
+	}
+	return anySubscription(l, o), true
+}
@@ -0,0 +1,121 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type deployRequestedEvent struct {
+	Environment string
+}
+
+func TestOn(t *testing.T) {
+	dispatcher := NewDispatcher()
+
+	var pushes []string
+	On(dispatcher, func(_ context.Context, event *gitlab.PushEvent) error {
+		pushes = append(pushes, event.Ref)
+		return nil
+	})
+	var deploys []string
+	On(dispatcher, func(_ context.Context, event *deployRequestedEvent) error {
+		deploys = append(deploys, event.Environment)
+		return nil
+	})
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypePush, loadFixture("testdata/webhooks/push.json")))
+	assert.NoError(t, dispatcher.Dispatch(ctx, &deployRequestedEvent{Environment: "production"}))
+	assert.ErrorIs(t, dispatcher.Dispatch(ctx, &struct{}{}), ErrUnsupportedEvent)
+
+	assert.Equal(t, []string{"refs/heads/master"}, pushes)
+	assert.Equal(t, []string{"production"}, deploys)
+}
+
+func TestListenerFunc(t *testing.T) {
+	var events []gitlab.EventType
+	dispatcher := NewDispatcher(RegisterListeners(
+		PushListenerFunc(func(context.Context, *gitlab.PushEvent) error {
+			events = append(events, gitlab.EventTypePush)
+			return nil
+		}),
+		IssueListenerFunc(func(context.Context, *gitlab.IssueEvent) error {
+			events = append(events, gitlab.EventTypeIssue)
+			return nil
+		}),
+	))
+
+	ctx := context.Background()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypePush, loadFixture("testdata/webhooks/push.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeIssue, loadFixture("testdata/webhooks/issue.json")))
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypeJob, loadFixture("testdata/webhooks/job.json")))
+
+	assert.Equal(t, []gitlab.EventType{gitlab.EventTypePush, gitlab.EventTypeIssue}, events)
+}
+
+func TestDispatcher_Unsubscribe(t *testing.T) {
+	listener := &countingPushListener{}
+	dispatcher := NewDispatcher()
+	unsubscribe := dispatcher.RegisterListeners(listener)
+	unsubscribeOn := On(dispatcher, func(context.Context, *deployRequestedEvent) error { return nil })
+
+	ctx := context.Background()
+	payload := loadFixture("testdata/webhooks/push.json")
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypePush, payload))
+	assert.NoError(t, dispatcher.Dispatch(ctx, &deployRequestedEvent{}))
+
+	unsubscribe()
+	unsubscribe()
+	unsubscribeOn()
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypePush, payload))
+	assert.ErrorIs(t, dispatcher.Dispatch(ctx, &deployRequestedEvent{}), ErrUnsupportedEvent)
+
+	assert.Equal(t, int64(1), listener.calls.Load())
+}
+
+func TestDispatcher_UnsubscribeKeepsOthers(t *testing.T) {
+	first, second := &countingPushListener{}, &countingPushListener{}
+	dispatcher := NewDispatcher()
+	unsubscribe := dispatcher.RegisterPushListener(first)
+	dispatcher.RegisterPushListener(second)
+
+	unsubscribe()
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{}))
+
+	assert.Equal(t, int64(0), first.calls.Load())
+	assert.Equal(t, int64(1), second.calls.Load())
+}
+
+func TestDispatcher_ConcurrentRegistration(t *testing.T) {
+	const goroutines, iterations = 4, 100
+
+	dispatcher := NewDispatcher(WithConcurrency(goroutines))
+	payload := loadFixture("testdata/webhooks/push.json")
+
+	wg := sync.WaitGroup{}
+	for range goroutines {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				unsubscribe := dispatcher.RegisterListeners(&countingPushListener{})
+				unsubscribeOn := On(dispatcher, func(context.Context, *gitlab.PushEvent) error { return nil })
+				unsubscribe()
+				unsubscribeOn()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range iterations {
+				assert.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypePush, payload))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Empty(t, dispatcher.loadRegistry().listeners)
+}
@@ -0,0 +1,112 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// DispatchReport describes what became of a delivery and each listener that
+// received it.
+type DispatchReport struct {
+	EventType gitlab.EventType `json:"event_type"`
+	// DeliveryID is the Idempotency-Key or X-Gitlab-Event-UUID header of the
+	// request, if any.
+	DeliveryID string           `json:"delivery_id,omitempty"`
+	Status     DispatchStatus   `json:"status"`
+	Listeners  []ListenerResult `json:"listeners"`
+
+	recorded bool
+}
+
+// ListenerResult is the outcome of a single listener, including its retries.
+type ListenerResult struct {
+	ListenerType string
+	Duration     time.Duration
+	Err          error
+	// Panicked reports whether Err is a ListenerPanicError.
+	Panicked bool
+	// TimedOut reports whether Err is a ListenerTimeoutError.
+	TimedOut bool
+}
+
+// MarshalJSON encodes the result with its error message and a human readable
+// duration.
+func (r ListenerResult) MarshalJSON() ([]byte, error) {
+	result := struct {
+		ListenerType string `json:"listener_type"`
+		Duration     string `json:"duration"`
+		Error        string `json:"error,omitempty"`
+		Panicked     bool   `json:"panicked,omitempty"`
+		TimedOut     bool   `json:"timed_out,omitempty"`
+	}{
+		ListenerType: r.ListenerType,
+		Duration:     r.Duration.String(),
+		Panicked:     r.Panicked,
+		TimedOut:     r.TimedOut,
+	}
+	if r.Err != nil {
+		result.Error = r.Err.Error()
+	}
+	return json.Marshal(result)
+}
+
+// DispatchWithReport dispatches event like Dispatch and reports the outcome
+// of each listener. The report is never nil.
+func (d *Dispatcher) DispatchWithReport(ctx context.Context, event any) (*DispatchReport, error) {
+	report := &DispatchReport{}
+	err := d.dispatch(ctx, event, report)
+	return report, err
+}
+
+// DispatchRequestWithReport fills report with the outcome of the delivery
+// once the request is validated.
+func DispatchRequestWithReport(report *DispatchReport) DispatchRequestOption {
+	return func(o *dispatchRequestOptions) {
+		o.report = report
+	}
+}
+
+func (r *DispatchReport) setStatus(status DispatchStatus) {
+	if r != nil {
+		r.Status = status
+		r.recorded = true
+	}
+}
+
+// record sets up the report for n listeners and returns their results.
+func (r *DispatchReport) record(n int) []ListenerResult {
+	if n == 0 {
+		r.setStatus(DispatchStatusNoListeners)
+	} else {
+		r.setStatus(DispatchStatusDispatched)
+	}
+	r.Listeners = make([]ListenerResult, n)
+	return r.Listeners
+}
+
+// reportListener runs listener r, recording its outcome in results[i] unless
+// results is nil.
+func reportListener(ctx context.Context, d *Dispatcher, eventType gitlab.EventType, r registration, event any, results []ListenerResult, i int) error { //nolint:lll
+	if results == nil {
+		return runListener(ctx, d, eventType, r, event)
+	}
+
+	start := time.Now()
+	err := runListener(ctx, d, eventType, r, event)
+
+	var panicked *ListenerPanicError
+	var timedOut *ListenerTimeoutError
+	results[i] = ListenerResult{
+		ListenerType: fmt.Sprintf("%T", r.listener),
+		Duration:     time.Since(start),
+		Err:          err,
+		Panicked:     errors.As(err, &panicked),
+		TimedOut:     errors.As(err, &timedOut),
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestDispatcher_DispatchWithReport(t *testing.T) {
+	slow := &slowTimeoutPushListener{slowPushListener{timeout: 10 * time.Millisecond, cancelled: make(chan error, 1)}}
+	dispatcher := NewDispatcher(RegisterListeners(
+		&countingPushListener{},
+		failingPushListener{},
+		&panickingPushListener{value: "boom"},
+		slow,
+	))
+
+	report, err := dispatcher.DispatchWithReport(context.Background(), &gitlab.PushEvent{})
+	require.Error(t, err)
+	assert.Equal(t, gitlab.EventTypePush, report.EventType)
+	assert.Equal(t, DispatchStatusDispatched, report.Status)
+	require.Len(t, report.Listeners, 4)
+
+	ok, failed, panicked, timedOut := report.Listeners[0], report.Listeners[1], report.Listeners[2], report.Listeners[3]
+	assert.Equal(t, "*gitlabwebhook.countingPushListener", ok.ListenerType)
+	assert.NoError(t, ok.Err)
+	assert.Equal(t, "gitlabwebhook.failingPushListener", failed.ListenerType)
+	assert.EqualError(t, failed.Err, "downstream unavailable")
+	assert.False(t, failed.Panicked)
+	assert.True(t, panicked.Panicked)
+	assert.False(t, panicked.TimedOut)
+	assert.True(t, timedOut.TimedOut)
+	assert.ErrorIs(t, timedOut.Err, ErrListenerTimeout)
+	assert.GreaterOrEqual(t, timedOut.Duration, 10*time.Millisecond)
+}
+
+func TestDispatcher_DispatchWithReportStatus(t *testing.T) {
+	dispatcher := NewDispatcher()
+	dispatcher.RegisterPushListener(&countingPushListener{}, WithRef("refs/heads/release"))
+
+	report, err := dispatcher.DispatchWithReport(context.Background(), &gitlab.PushEvent{Ref: "refs/heads/main"})
+	require.NoError(t, err)
+	assert.Equal(t, DispatchStatusNoListeners, report.Status)
+	assert.Empty(t, report.Listeners)
+
+	report, err = dispatcher.DispatchWithReport(context.Background(), &struct{}{})
+	require.ErrorIs(t, err, ErrUnsupportedEvent)
+	assert.Equal(t, DispatchStatusUnsupported, report.Status)
+}
+
+func TestDispatchRequestWithReport(t *testing.T) {
+	req := newPushRequest(t)
+	req.Header.Set("X-Gitlab-Event-UUID", "event-uuid")
+
+	var report DispatchReport
+	require.NoError(t, NewDispatcher().DispatchRequest(req, DispatchRequestWithReport(&report)))
+	assert.Equal(t, DispatchReport{
+		EventType:  gitlab.EventTypePush,
+		DeliveryID: "event-uuid",
+		Status:     DispatchStatusNoListeners,
+		recorded:   true,
+	}, report)
+
+	req = newPushRequest(t)
+	req.Header.Set("X-Gitlab-Event-UUID", "event-uuid")
+	report = DispatchReport{}
+	require.NoError(t, NewDispatcher(RegisterListeners(&countingPushListener{})).DispatchRequest(req, DispatchRequestWithReport(&report)))
+	assert.Equal(t, "event-uuid", report.DeliveryID)
+	assert.Equal(t, DispatchStatusDispatched, report.Status)
+	assert.Len(t, report.Listeners, 1)
+}
+
+func TestDispatchRequestWithReportDuplicate(t *testing.T) {
+	dispatcher := NewDispatcher(RegisterListeners(&countingPushListener{}))
+	handler := dispatcher.Handler(HandlerWithReport(), HandlerWithDispatchRequestOptions(
+		DispatchRequestWithDedupStore(NewMemoryDedupStore(time.Hour, 10)),
+	))
+
+	for _, status := range []string{"dispatched", "duplicate"} {
+		req := newPushRequest(t)
+		req.Header.Set("X-Gitlab-Event-UUID", "event-uuid")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var report struct {
+			Status string `json:"status"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		assert.Equal(t, status, report.Status)
+	}
+}
+
+func TestDispatcher_HandlerWithReport(t *testing.T) {
+	dispatcher := NewDispatcher(RegisterListeners(&countingPushListener{}, failingPushListener{}))
+	handler := dispatcher.Handler(HandlerWithReport(), HandlerWithDispatchRequestOptions(DispatchRequestWithToken("token")))
+
+	req := newPushRequest(t)
+	req.Header.Set("X-Gitlab-Token", "token")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var body struct {
+		EventType string `json:"event_type"`
+		Status    string `json:"status"`
+		Listeners []struct {
+			ListenerType string `json:"listener_type"`
+			Duration     string `json:"duration"`
+			Error        string `json:"error"`
+		} `json:"listeners"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "Push Hook", body.EventType)
+	assert.Equal(t, "dispatched", body.Status)
+	require.Len(t, body.Listeners, 2)
+	assert.Empty(t, body.Listeners[0].Error)
+	assert.NotEmpty(t, body.Listeners[0].Duration)
+	assert.Equal(t, "downstream unavailable", body.Listeners[1].Error)
+
+	// unsupported events are reported with the configured status
+	req = newPushRequest(t)
+	req.Header.Set("X-Gitlab-Token", "token")
+	req.Header.Set("X-Gitlab-Event", "Unknown Hook")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.JSONEq(t, `{"event_type":"Unknown Hook","status":"unsupported","listeners":null}`, rec.Body.String())
+
+	// deliveries failing validation are not reported
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newPushRequest(t))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEqual(t, "application/json", rec.Header().Get("Content-Type"))
+}
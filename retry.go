@@ -0,0 +1,148 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+const (
+	defaultInitialBackoff    = 100 * time.Millisecond
+	defaultBackoffMultiplier = 2
+)
+
+// RetryPolicy controls how often a failing listener is invoked again.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of invocations, including the first.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. Zero means no cap.
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after every attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter randomly shortens every wait by up to this fraction, between 0 and 1.
+	Jitter float64
+	// RetryableOnly retries only errors marked with Retryable. By default
+	// every error is retried unless it is marked with Permanent.
+	RetryableOnly bool
+}
+
+// RetryPolicyListener can be implemented by listeners to override the
+// dispatcher's RetryPolicy for themselves.
+type RetryPolicyListener interface {
+	RetryPolicy() RetryPolicy
+}
+
+// WithRetryPolicy sets the default RetryPolicy for all listeners.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(d *Dispatcher) {
+		d.retryPolicy = policy
+	}
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+type retryableError struct{ err error }
+
+func (e *retryableError) Error() string { return e.err.Error() }
+func (e *retryableError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retryable marks err as worth retrying.
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &retryableError{err: err}
+}
+
+func (p RetryPolicy) shouldRetry(err error) bool {
+	var permanent *permanentError
+	var panicked *ListenerPanicError
+	if errors.As(err, &permanent) || errors.As(err, &panicked) {
+		return false
+	}
+	if p.RetryableOnly {
+		var retryable *retryableError
+		return errors.As(err, &retryable)
+	}
+	return true
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	if delay <= 0 {
+		delay = defaultInitialBackoff
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultBackoffMultiplier
+	}
+
+	for range attempt - 1 {
+		delay = time.Duration(float64(delay) * multiplier)
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			delay = p.MaxBackoff
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(rand.Float64() * min(p.Jitter, 1) * float64(delay))
+	}
+	return delay
+}
+
+// runListener invokes listener, retrying failed attempts as the retry policy
+// for listener allows within its timeout, and hands the final error to the
+// dead letter sink.
+func runListener(ctx context.Context, d *Dispatcher, eventType gitlab.EventType, r registration, event any) error {
+	var err error
+	if timeout := d.listenerTimeoutFor(eventType, r.listener); timeout > 0 {
+		err = timeoutListener(ctx, d, eventType, r, event, timeout)
+	} else {
+		err = retryListener(ctx, d, eventType, r, event)
+	}
+	if err != nil && d.deadLetterSink != nil {
+		if sinkErr := d.deadLetter(ctx, eventType, r.listener, event, err); sinkErr != nil {
+			err = errors.Join(err, sinkErr)
+		}
+	}
+	return err
+}
+
+func retryListener(ctx context.Context, d *Dispatcher, eventType gitlab.EventType, r registration, event any) error {
+	policy := d.retryPolicy
+	if l, ok := r.listener.(RetryPolicyListener); ok {
+		policy = l.RetryPolicy()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := invokeListener(ctx, d, eventType, r, event)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.shouldRetry(err) {
+			return err
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
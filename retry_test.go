@@ -0,0 +1,147 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+var errTransient = errors.New("503 from downstream")
+
+type flakyPushListener struct {
+	calls    atomic.Int64
+	failures int64
+	wrap     func(error) error
+	policy   *RetryPolicy
+}
+
+func (f *flakyPushListener) OnPush(context.Context, *gitlab.PushEvent) error {
+	if f.calls.Add(1) <= f.failures {
+		if f.wrap != nil {
+			return f.wrap(errTransient)
+		}
+		return errTransient
+	}
+	return nil
+}
+
+type flakyPushListenerWithPolicy struct {
+	flakyPushListener
+}
+
+func (f *flakyPushListenerWithPolicy) RetryPolicy() RetryPolicy {
+	return *f.policy
+}
+
+func TestDispatcher_WithRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		listener  PushListener
+		wantErr   bool
+		wantCalls int64
+	}{
+		{
+			name:      "recovers within attempts",
+			policy:    policy,
+			listener:  &flakyPushListener{failures: 2},
+			wantCalls: 3,
+		},
+		{
+			name:      "gives up after max attempts",
+			policy:    policy,
+			listener:  &flakyPushListener{failures: 5},
+			wantErr:   true,
+			wantCalls: 3,
+		},
+		{
+			name:      "permanent errors are not retried",
+			policy:    policy,
+			listener:  &flakyPushListener{failures: 5, wrap: Permanent},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "unmarked errors are not retried when retryable only",
+			policy:    RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryableOnly: true},
+			listener:  &flakyPushListener{failures: 1},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+		{
+			name:      "retryable errors are retried when retryable only",
+			policy:    RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryableOnly: true},
+			listener:  &flakyPushListener{failures: 1, wrap: Retryable},
+			wantCalls: 2,
+		},
+		{
+			name:   "listener overrides policy",
+			policy: RetryPolicy{},
+			listener: &flakyPushListenerWithPolicy{flakyPushListener{
+				failures: 1,
+				policy:   &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+			}},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispatcher := NewDispatcher(WithRetryPolicy(tt.policy), RegisterListeners(tt.listener))
+
+			err := dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errTransient)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			var calls int64
+			switch l := tt.listener.(type) {
+			case *flakyPushListener:
+				calls = l.calls.Load()
+			case *flakyPushListenerWithPolicy:
+				calls = l.calls.Load()
+			}
+			assert.Equal(t, tt.wantCalls, calls)
+		})
+	}
+}
+
+func TestDispatcher_RetryHonorsContext(t *testing.T) {
+	listener := &flakyPushListener{failures: 5}
+	dispatcher := NewDispatcher(
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}),
+		RegisterListeners(listener),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := dispatcher.Dispatch(ctx, &gitlab.PushEvent{})
+	assert.ErrorIs(t, err, errTransient)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int64(1), listener.calls.Load())
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(4))
+
+	policy.Jitter = 0.5
+	for range 100 {
+		delay := policy.backoff(2)
+		assert.GreaterOrEqual(t, delay, 10*time.Millisecond)
+		assert.LessOrEqual(t, delay, 20*time.Millisecond)
+	}
+}
@@ -0,0 +1,95 @@
+package gitlabwebhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signatureIDHeader        = "webhook-id"
+	signatureTimestampHeader = "webhook-timestamp"
+	signatureHeader          = "webhook-signature"
+
+	signatureVersion = "v1"
+	secretPrefix     = "whsec_"
+
+	// DefaultSignatureTolerance is how far a delivery timestamp may drift from
+	// the local clock before the delivery is rejected as stale.
+	DefaultSignatureTolerance = 5 * time.Minute
+)
+
+// DispatchRequestWithSigningSecret verifies the Standard Webhooks signature
+// GitLab attaches to deliveries of hooks configured with a signing token.
+// Several secrets may be given to accept deliveries during a rotation.
+// Secrets with a "whsec_" prefix are base64 decoded, as GitLab displays them.
+func DispatchRequestWithSigningSecret(secrets ...string) DispatchRequestOption {
+	return func(o *dispatchRequestOptions) {
+		for _, secret := range secrets {
+			o.signingSecrets = append(o.signingSecrets, decodeSigningSecret(secret))
+		}
+	}
+}
+
+// DispatchRequestWithSignatureTolerance overrides DefaultSignatureTolerance.
+func DispatchRequestWithSignatureTolerance(tolerance time.Duration) DispatchRequestOption {
+	return func(o *dispatchRequestOptions) {
+		o.signatureTolerance = tolerance
+	}
+}
+
+func decodeSigningSecret(secret string) []byte {
+	encoded, ok := strings.CutPrefix(secret, secretPrefix)
+	if !ok {
+		return []byte(secret)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return []byte(secret)
+	}
+	return decoded
+}
+
+func verifySignature(header http.Header, payload []byte, secrets [][]byte, tolerance time.Duration, now time.Time) error {
+	id := header.Get(signatureIDHeader)
+	timestamp := header.Get(signatureTimestampHeader)
+	signatures := header.Get(signatureHeader)
+	if id == "" || timestamp == "" || signatures == "" {
+		return ErrInvalidSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if drift := now.Sub(time.Unix(seconds, 0)).Abs(); drift > tolerance {
+		return ErrStaleDelivery
+	}
+
+	for _, secret := range secrets {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(id + "." + timestamp + "."))
+		mac.Write(payload)
+		expected := mac.Sum(nil)
+
+		for _, signature := range strings.Fields(signatures) {
+			version, encoded, ok := strings.Cut(signature, ",")
+			if !ok || version != signatureVersion {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				continue
+			}
+			if hmac.Equal(decoded, expected) {
+				return nil
+			}
+		}
+	}
+
+	return ErrInvalidSignature
+}
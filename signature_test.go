@@ -0,0 +1,117 @@
+package gitlabwebhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func signPayload(secret []byte, id string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestDispatcher_DispatchRequestWithSigningSecret(t *testing.T) {
+	payload := loadFixture("testdata/webhooks/push.json")
+	secret := []byte("signing-secret")
+	encodedSecret := "whsec_" + base64.StdEncoding.EncodeToString(secret)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name          string
+		secrets       []string
+		timestamp     int64
+		signature     string
+		opts          []DispatchRequestOption
+		expectedError error
+	}{
+		{
+			name:      "valid signature",
+			secrets:   []string{string(secret)},
+			timestamp: now,
+			signature: signPayload(secret, "msg_1", now, payload),
+		},
+		{
+			name:      "valid signature with prefixed secret",
+			secrets:   []string{encodedSecret},
+			timestamp: now,
+			signature: signPayload(secret, "msg_1", now, payload),
+		},
+		{
+			name:      "rotated secret",
+			secrets:   []string{"new-secret", string(secret)},
+			timestamp: now,
+			signature: signPayload(secret, "msg_1", now, payload),
+		},
+		{
+			name:      "one of several signatures matches",
+			secrets:   []string{string(secret)},
+			timestamp: now,
+			signature: signPayload([]byte("other"), "msg_1", now, payload) + " " + signPayload(secret, "msg_1", now, payload),
+		},
+		{
+			name:          "wrong secret",
+			secrets:       []string{"wrong-secret"},
+			timestamp:     now,
+			signature:     signPayload(secret, "msg_1", now, payload),
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			name:          "missing signature",
+			secrets:       []string{string(secret)},
+			timestamp:     now,
+			expectedError: ErrInvalidSignature,
+		},
+		{
+			name:          "stale delivery",
+			secrets:       []string{string(secret)},
+			timestamp:     now - int64(time.Hour/time.Second),
+			signature:     signPayload(secret, "msg_1", now-int64(time.Hour/time.Second), payload),
+			expectedError: ErrStaleDelivery,
+		},
+		{
+			name:      "stale delivery within custom tolerance",
+			secrets:   []string{string(secret)},
+			timestamp: now - int64(time.Hour/time.Second),
+			signature: signPayload(secret, "msg_1", now-int64(time.Hour/time.Second), payload),
+			opts:      []DispatchRequestOption{DispatchRequestWithSignatureTolerance(2 * time.Hour)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener := &simpleTestListener{}
+			dispatcher := NewDispatcher(RegisterListeners(listener))
+
+			req, err := http.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(payload))
+			assert.NoError(t, err)
+			req.Header.Set("X-Gitlab-Event", string(gitlab.EventTypePush))
+			req.Header.Set("webhook-id", "msg_1")
+			req.Header.Set("webhook-timestamp", strconv.FormatInt(tt.timestamp, 10))
+			if tt.signature != "" {
+				req.Header.Set("webhook-signature", tt.signature)
+			}
+
+			opts := append([]DispatchRequestOption{DispatchRequestWithSigningSecret(tt.secrets...)}, tt.opts...)
+			err = dispatcher.DispatchRequest(req, opts...)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				assert.False(t, listener.called)
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, listener.called)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package gitlabwebhook
+
+import (
+	"net/http"
+	"reflect"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// DispatchStatus describes what became of a delivery.
+type DispatchStatus int
+
+const (
+	// DispatchStatusRejected means the request was not dispatched, because it
+	// failed validation or its payload could not be decoded.
+	DispatchStatusRejected DispatchStatus = iota
+	// DispatchStatusDispatched means the event was passed to its listeners.
+	DispatchStatusDispatched
+	// DispatchStatusNoListeners means no listener received the event.
+	// Requests of event types nothing listens for are acknowledged without
+	// decoding their payload.
+	DispatchStatusNoListeners
+	// DispatchStatusUnsupported means the event type is not supported, and
+	// the delivery failed with ErrUnsupportedEvent.
+	DispatchStatusUnsupported
+	// DispatchStatusDuplicate means the delivery was already processed, and
+	// failed with ErrDuplicateDelivery.
+	DispatchStatusDuplicate
+)
+
+func (s DispatchStatus) String() string {
+	switch s {
+	case DispatchStatusRejected:
+		return "rejected"
+	case DispatchStatusDispatched:
+		return "dispatched"
+	case DispatchStatusNoListeners:
+		return "no listeners"
+	case DispatchStatusUnsupported:
+		return "unsupported"
+	case DispatchStatusDuplicate:
+		return "duplicate"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler using String.
+func (s DispatchStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// DispatchRequestWithStatus stores what became of the delivery in status.
+func DispatchRequestWithStatus(status *DispatchStatus) DispatchRequestOption {
+	return func(o *dispatchRequestOptions) {
+		o.status = status
+	}
+}
+
+// HasListeners reports whether any listener may receive deliveries of
+// eventType. Deliveries nobody listens for are acknowledged without decoding
+// their payload.
+func (d *Dispatcher) HasListeners(eventType gitlab.EventType) bool {
+	r := d.loadRegistry()
+	if len(r.listeners[listenerKey{}]) > 0 || r.hookListeners[eventType] > 0 {
+		return true
+	}
+	// listeners of event types defined outside this package receive whatever
+	// registered decoders return
+	return r.untypedListeners > 0 && r.hasDecoder(eventType)
+}
+
+// hookEventTypes returns the webhook event types events of typ are
+// delivered with, or nil if typ is not a GitLab event.
+func hookEventTypes(typ reflect.Type) []gitlab.EventType {
+	eventType := eventTypeOf(reflect.Zero(typ).Interface())
+	switch eventType {
+	case "":
+		return nil
+	case gitlab.EventTypeIssue:
+		return []gitlab.EventType{eventType, gitlab.EventConfidentialIssue}
+	case gitlab.EventTypeNote:
+		return []gitlab.EventType{eventType, gitlab.EventConfidentialNote}
+	case gitlab.EventTypeMergeRequest:
+		// system hooks deliver merge requests as project hooks do
+		return []gitlab.EventType{eventType, gitlab.EventTypeServiceHook, gitlab.EventTypeSystemHook}
+	case gitlab.EventTypePush, gitlab.EventTypeTagPush:
+		return []gitlab.EventType{eventType, gitlab.EventTypeServiceHook}
+	default:
+		return []gitlab.EventType{eventType}
+	}
+}
+
+// clientEventTypes are the event types the gitlab client decodes.
+var clientEventTypes = map[gitlab.EventType]bool{
+	gitlab.EventTypeBuild:               true,
+	gitlab.EventTypeDeployment:          true,
+	gitlab.EventTypeFeatureFlag:         true,
+	gitlab.EventTypeIssue:               true,
+	gitlab.EventConfidentialIssue:       true,
+	gitlab.EventTypeJob:                 true,
+	gitlab.EventTypeMember:              true,
+	gitlab.EventTypeMergeRequest:        true,
+	gitlab.EventTypeMilestone:           true,
+	gitlab.EventTypeNote:                true,
+	gitlab.EventConfidentialNote:        true,
+	gitlab.EventTypePipeline:            true,
+	gitlab.EventTypeProject:             true,
+	gitlab.EventTypePush:                true,
+	gitlab.EventTypeRelease:             true,
+	gitlab.EventTypeResourceAccessToken: true,
+	gitlab.EventTypeServiceHook:         true,
+	gitlab.EventTypeSubGroup:            true,
+	gitlab.EventTypeSystemHook:          true,
+	gitlab.EventTypeTagPush:             true,
+	gitlab.EventTypeVulnerability:       true,
+	gitlab.EventTypeWikiPage:            true,
+}
+
+// skipDecoding reports whether deliveries of eventType can be acknowledged
+// without decoding them. Unknown event types are still decoded, so they are
+// reported as unsupported.
+func (d *Dispatcher) skipDecoding(eventType gitlab.EventType) bool {
+	if d.HasListeners(eventType) {
+		return false
+	}
+	return clientEventTypes[eventType] || d.loadRegistry().hasDecoder(eventType)
+}
+
+func (o *dispatchRequestOptions) setStatus(req *http.Request, status DispatchStatus) {
+	if o.status != nil {
+		*o.status = status
+	}
+	if o.report != nil {
+		o.report.EventType = gitlab.HookEventType(req)
+		o.report.DeliveryID = dedupKey(req.Header)
+		o.report.setStatus(status)
+	}
+}
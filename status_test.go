@@ -0,0 +1,120 @@
+package gitlabwebhook
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestDispatcher_HasListeners(t *testing.T) {
+	dispatcher := NewDispatcher()
+	assert.False(t, dispatcher.HasListeners(gitlab.EventTypePush))
+
+	unsubscribe := dispatcher.RegisterListeners(&countingPushListener{})
+	assert.True(t, dispatcher.HasListeners(gitlab.EventTypePush))
+	assert.True(t, dispatcher.HasListeners(gitlab.EventTypeServiceHook))
+	assert.False(t, dispatcher.HasListeners(gitlab.EventTypeIssue))
+
+	unsubscribe()
+	assert.False(t, dispatcher.HasListeners(gitlab.EventTypePush))
+
+	dispatcher.RegisterConfidentialNoteListener(ConfidentialNoteListenerFunc(func(context.Context, *gitlab.IssueCommentEvent) error {
+		return nil
+	}))
+	assert.True(t, dispatcher.HasListeners(gitlab.EventConfidentialNote))
+	assert.True(t, dispatcher.HasListeners(gitlab.EventTypeNote))
+
+	dispatcher.RegisterListeners(UserSystemListenerFunc(func(context.Context, *gitlab.UserSystemEvent) error {
+		return nil
+	}))
+	assert.True(t, dispatcher.HasListeners(gitlab.EventTypeSystemHook))
+
+	// listeners of custom event types may receive anything a decoder returns
+	dispatcher.RegisterEventType("Audit Hook", decodeJSON[auditEvent])
+	assert.False(t, dispatcher.HasListeners("Audit Hook"))
+	On(dispatcher, func(context.Context, *auditEvent) error { return nil })
+	assert.True(t, dispatcher.HasListeners("Audit Hook"))
+	assert.False(t, dispatcher.HasListeners(gitlab.EventTypePipeline))
+
+	dispatcher.RegisterAnyListener(AnyListenerFunc(func(context.Context, gitlab.EventType, any) error { return nil }))
+	assert.True(t, dispatcher.HasListeners(gitlab.EventTypePipeline))
+}
+
+func TestDispatcher_SkipsDecodingWithoutListeners(t *testing.T) {
+	dispatcher := NewDispatcher(RegisterListeners(&countingPushListener{}))
+
+	ctx := context.Background()
+	// the payload is not decoded, so it is not found invalid
+	assert.NoError(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypePipeline, []byte(`{`)))
+	assert.ErrorIs(t, dispatcher.DispatchWebhook(ctx, gitlab.EventTypePush, []byte(`{`)), ErrInvalidPayload)
+	// unknown event types are still reported
+	assert.ErrorIs(t, dispatcher.DispatchWebhook(ctx, "Unknown Hook", []byte(`{}`)), ErrUnsupportedEvent)
+}
+
+func TestDispatchRequestWithStatus(t *testing.T) {
+	status := DispatchStatus(-1)
+	require.NoError(t, NewDispatcher().DispatchRequest(newPushRequest(t), DispatchRequestWithStatus(&status)))
+	assert.Equal(t, DispatchStatusNoListeners, status)
+	assert.Equal(t, "no listeners", status.String())
+
+	dispatcher := NewDispatcher(RegisterListeners(&countingPushListener{}))
+	require.NoError(t, dispatcher.DispatchRequest(newPushRequest(t), DispatchRequestWithStatus(&status)))
+	assert.Equal(t, DispatchStatusDispatched, status)
+
+	// routed to no listener after all
+	filtered := NewDispatcher()
+	filtered.RegisterPushListener(&countingPushListener{}, WithProject("nope/*"))
+	require.NoError(t, filtered.DispatchRequest(newPushRequest(t), DispatchRequestWithStatus(&status)))
+	assert.Equal(t, DispatchStatusNoListeners, status)
+
+	confidential := NewDispatcher(RegisterListeners(ConfidentialIssueListenerFunc(func(context.Context, *gitlab.IssueEvent) error {
+		return nil
+	})))
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(loadFixture("testdata/webhooks/issue.json")))
+	req.Header.Set("X-Gitlab-Event", string(gitlab.EventTypeIssue))
+	require.NoError(t, confidential.DispatchRequest(req, DispatchRequestWithStatus(&status)))
+	assert.Equal(t, DispatchStatusNoListeners, status)
+
+	err := dispatcher.DispatchRequest(newPushRequest(t), DispatchRequestWithToken("token"), DispatchRequestWithStatus(&status))
+	require.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, DispatchStatusRejected, status)
+	assert.Equal(t, "rejected", status.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("{"))
+	req.Header.Set("X-Gitlab-Event", string(gitlab.EventTypePush))
+	require.ErrorIs(t, dispatcher.DispatchRequest(req, DispatchRequestWithStatus(&status)), ErrInvalidPayload)
+	assert.Equal(t, DispatchStatusRejected, status)
+}
+
+func BenchmarkDispatcher_DispatchWebhook(b *testing.B) {
+	payload := loadFixture("testdata/webhooks/pipeline.json")
+
+	b.Run("pipeline listener", func(b *testing.B) {
+		dispatcher := NewDispatcher()
+		On(dispatcher, func(context.Context, *gitlab.PipelineEvent) error { return nil })
+		benchmarkDispatchWebhook(b, dispatcher, payload)
+	})
+	b.Run("no pipeline listener", func(b *testing.B) {
+		benchmarkDispatchWebhook(b, NewDispatcher(RegisterListeners(&countingPushListener{})), payload)
+	})
+}
+
+func benchmarkDispatchWebhook(b *testing.B, dispatcher *Dispatcher, payload []byte) {
+	b.Helper()
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	for b.Loop() {
+		if err := dispatcher.DispatchWebhook(ctx, gitlab.EventTypePipeline, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
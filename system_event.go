@@ -0,0 +1,64 @@
+package gitlabwebhook
+
+import (
+	"encoding/json"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// The gitlab client library (gitlab.com/gitlab-org/api/client-go) does not currently parse system hooks for
+// member access requests, so we define them here.
+
+// AccessRequestSystemEvent represents a user requesting, or revoking a request
+// for, access to a group or project. Depending on EventName either the Group
+// or the Project fields are set.
+type AccessRequestSystemEvent struct {
+	gitlab.BaseSystemEvent
+	UserID       int64  `json:"user_id"`
+	UserName     string `json:"user_name"`
+	UserUsername string `json:"user_username"`
+	UserEmail    string `json:"user_email"`
+
+	GroupID     int64  `json:"group_id,omitempty"`
+	GroupName   string `json:"group_name,omitempty"`
+	GroupPath   string `json:"group_path,omitempty"`
+	GroupAccess string `json:"group_access,omitempty"`
+
+	ProjectID                int64  `json:"project_id,omitempty"`
+	ProjectName              string `json:"project_name,omitempty"`
+	ProjectPath              string `json:"project_path,omitempty"`
+	ProjectPathWithNamespace string `json:"project_path_with_namespace,omitempty"`
+	ProjectVisibility        string `json:"project_visibility,omitempty"`
+	AccessLevel              string `json:"access_level,omitempty"`
+}
+
+func parseSystemHook(payload []byte) (any, error) {
+	var base gitlab.BaseSystemEvent
+	if err := json.Unmarshal(payload, &base); err != nil {
+		return nil, err
+	}
+
+	if !isAccessRequest(base.EventName) {
+		return gitlab.ParseSystemhook(payload)
+	}
+
+	var event AccessRequestSystemEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// isAccessRequest reports whether a member or system hook event is about a
+// request for access rather than an actual membership change.
+func isAccessRequest(eventName string) bool {
+	switch eventName {
+	case "user_access_request_to_group",
+		"user_access_request_revoked_for_group",
+		"user_access_request_to_project",
+		"user_access_request_revoked_for_project":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,158 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type systemTestListener struct {
+	events []any
+}
+
+var (
+	_ AccessRequestSystemListener    = (*systemTestListener)(nil)
+	_ GroupSystemListener            = (*systemTestListener)(nil)
+	_ KeySystemListener              = (*systemTestListener)(nil)
+	_ MergeListener                  = (*systemTestListener)(nil)
+	_ ProjectSystemListener          = (*systemTestListener)(nil)
+	_ PushSystemListener             = (*systemTestListener)(nil)
+	_ RepositoryUpdateSystemListener = (*systemTestListener)(nil)
+	_ TagPushSystemListener          = (*systemTestListener)(nil)
+	_ UserGroupSystemListener        = (*systemTestListener)(nil)
+	_ UserSystemListener             = (*systemTestListener)(nil)
+	_ UserTeamSystemListener         = (*systemTestListener)(nil)
+)
+
+func (s *systemTestListener) record(event any) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *systemTestListener) OnAccessRequestSystem(_ context.Context, event *AccessRequestSystemEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnGroupSystem(_ context.Context, event *gitlab.GroupSystemEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnKeySystem(_ context.Context, event *gitlab.KeySystemEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnMerge(_ context.Context, event *gitlab.MergeEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnProjectSystem(_ context.Context, event *gitlab.ProjectSystemEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnPushSystem(_ context.Context, event *gitlab.PushSystemEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnRepositoryUpdateSystem(_ context.Context, event *gitlab.RepositoryUpdateSystemEvent) error { //nolint:lll
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnTagPushSystem(_ context.Context, event *gitlab.TagPushSystemEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnUserGroupSystem(_ context.Context, event *gitlab.UserGroupSystemEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnUserSystem(_ context.Context, event *gitlab.UserSystemEvent) error {
+	return s.record(event)
+}
+
+func (s *systemTestListener) OnUserTeamSystem(_ context.Context, event *gitlab.UserTeamSystemEvent) error {
+	return s.record(event)
+}
+
+func TestDispatcher_DispatchSystemHook(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    any
+	}{
+		{"group_create", &gitlab.GroupSystemEvent{}},
+		{"group_destroy", &gitlab.GroupSystemEvent{}},
+		{"group_rename", &gitlab.GroupSystemEvent{}},
+		{"key_create", &gitlab.KeySystemEvent{}},
+		{"key_destroy", &gitlab.KeySystemEvent{}},
+		{"merge_request", &gitlab.MergeEvent{}},
+		{"project_create", &gitlab.ProjectSystemEvent{}},
+		{"project_destroy", &gitlab.ProjectSystemEvent{}},
+		{"project_rename", &gitlab.ProjectSystemEvent{}},
+		{"project_transfer", &gitlab.ProjectSystemEvent{}},
+		{"project_update", &gitlab.ProjectSystemEvent{}},
+		{"push", &gitlab.PushSystemEvent{}},
+		{"repository_update", &gitlab.RepositoryUpdateSystemEvent{}},
+		{"tag_push", &gitlab.TagPushSystemEvent{}},
+		{"user_access_request_to_group", &AccessRequestSystemEvent{}},
+		{"user_access_request_to_project", &AccessRequestSystemEvent{}},
+		{"user_add_to_group", &gitlab.UserGroupSystemEvent{}},
+		{"user_add_to_team", &gitlab.UserTeamSystemEvent{}},
+		{"user_create", &gitlab.UserSystemEvent{}},
+		{"user_destroy", &gitlab.UserSystemEvent{}},
+		{"user_failed_login", &gitlab.UserSystemEvent{}},
+		{"user_remove_from_group", &gitlab.UserGroupSystemEvent{}},
+		{"user_remove_from_team", &gitlab.UserTeamSystemEvent{}},
+		{"user_rename", &gitlab.UserSystemEvent{}},
+		{"user_update_for_group", &gitlab.UserGroupSystemEvent{}},
+		{"user_update_for_team", &gitlab.UserTeamSystemEvent{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			listener := &systemTestListener{}
+			dispatcher := NewDispatcher(RegisterListeners(listener))
+
+			payload := loadFixture(fmt.Sprintf("testdata/systemhooks/%s.json", tt.fixture))
+			require.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypeSystemHook, payload))
+
+			require.Len(t, listener.events, 1)
+			assert.IsType(t, tt.want, listener.events[0])
+		})
+	}
+}
+
+func TestDispatcher_DispatchSystemHookAccessRequest(t *testing.T) {
+	listener := &systemTestListener{}
+	dispatcher := NewDispatcher(RegisterListeners(listener))
+
+	payload := loadFixture("testdata/systemhooks/user_access_request_to_project.json")
+	require.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypeSystemHook, payload))
+
+	require.Len(t, listener.events, 1)
+	event := listener.events[0].(*AccessRequestSystemEvent)
+	assert.Equal(t, "user_access_request_to_project", event.EventName)
+	assert.Equal(t, "jsmith/storecloud", event.ProjectPathWithNamespace)
+	assert.Equal(t, "johnsmith", event.UserUsername)
+}
+
+func TestDispatcher_DispatchSystemHookMergeRequest(t *testing.T) {
+	var calls int
+	dispatcher := NewDispatcher(RegisterListeners(MergeListenerFunc(func(context.Context, *gitlab.MergeEvent) error {
+		calls++
+		return nil
+	})))
+
+	assert.True(t, dispatcher.HasListeners(gitlab.EventTypeSystemHook))
+	payload := loadFixture("testdata/systemhooks/merge_request.json")
+	require.NoError(t, dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypeSystemHook, payload))
+	assert.Equal(t, 1, calls)
+}
+
+func TestDispatcher_DispatchSystemHookUnsupported(t *testing.T) {
+	dispatcher := NewDispatcher(RegisterListeners(&systemTestListener{}))
+	err := dispatcher.DispatchWebhook(context.Background(), gitlab.EventTypeSystemHook, []byte(`{"event_name":"unknown"}`))
+	assert.ErrorIs(t, err, ErrUnsupportedEvent)
+}
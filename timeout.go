@@ -0,0 +1,99 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// ListenerTimeoutError is returned in place of the error of a listener that
+// did not return within its timeout. It matches ErrListenerTimeout and
+// context.DeadlineExceeded.
+type ListenerTimeoutError struct {
+	EventType    gitlab.EventType
+	ListenerType string
+	Timeout      time.Duration
+}
+
+func (e *ListenerTimeoutError) Error() string {
+	return fmt.Sprintf("gitlab-webhook: listener %s timed out after %s handling %s", e.ListenerType, e.Timeout, e.EventType)
+}
+
+func (e *ListenerTimeoutError) Unwrap() []error {
+	return []error{ErrListenerTimeout, context.DeadlineExceeded}
+}
+
+// TimeoutListener can be implemented by listeners to override the
+// dispatcher's listener timeout for themselves. Zero disables the timeout.
+type TimeoutListener interface {
+	ListenerTimeout() time.Duration
+}
+
+// WithListenerTimeout stops waiting for a listener after timeout, including
+// its retries, and cancels the context passed to it. The listener's result is
+// ErrListenerTimeout and the other listeners of the event are not held up.
+func WithListenerTimeout(timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.listenerTimeout = timeout
+	}
+}
+
+// WithEventTypeTimeout overrides the listener timeout for events of
+// eventType. Zero disables the timeout for them.
+func WithEventTypeTimeout(eventType gitlab.EventType, timeout time.Duration) Option {
+	return func(d *Dispatcher) {
+		if d.eventTypeTimeouts == nil {
+			d.eventTypeTimeouts = make(map[gitlab.EventType]time.Duration)
+		}
+		d.eventTypeTimeouts[eventType] = timeout
+	}
+}
+
+func (d *Dispatcher) listenerTimeoutFor(eventType gitlab.EventType, listener any) time.Duration {
+	if l, ok := listener.(TimeoutListener); ok {
+		return l.ListenerTimeout()
+	}
+	if timeout, ok := d.eventTypeTimeouts[eventType]; ok {
+		return timeout
+	}
+	return d.listenerTimeout
+}
+
+// timeoutListener runs the listener on its own goroutine and returns once it
+// does or timeout expires, whichever comes first.
+func timeoutListener(ctx context.Context, d *Dispatcher, eventType gitlab.EventType, r registration, event any, timeout time.Duration) error { //nolint:lll
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- retryListener(timeoutCtx, d, eventType, r, event)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			// the listener gave up on the deadline just before we did
+			return newListenerTimeoutError(eventType, r, timeout)
+		}
+		return err
+	case <-timeoutCtx.Done():
+		if ctx.Err() != nil {
+			// cancelled by the caller rather than the timeout, which the
+			// listener handles like it would without a timeout
+			return <-done
+		}
+		return newListenerTimeoutError(eventType, r, timeout)
+	}
+}
+
+func newListenerTimeoutError(eventType gitlab.EventType, r registration, timeout time.Duration) *ListenerTimeoutError {
+	return &ListenerTimeoutError{
+		EventType:    eventType,
+		ListenerType: fmt.Sprintf("%T", r.listener),
+		Timeout:      timeout,
+	}
+}
@@ -0,0 +1,83 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+type slowPushListener struct {
+	timeout   time.Duration
+	cancelled chan error
+}
+
+func (l *slowPushListener) OnPush(ctx context.Context, _ *gitlab.PushEvent) error {
+	<-ctx.Done()
+	l.cancelled <- ctx.Err()
+	return ctx.Err()
+}
+
+type slowTimeoutPushListener struct {
+	slowPushListener
+}
+
+func (l *slowTimeoutPushListener) ListenerTimeout() time.Duration {
+	return l.timeout
+}
+
+func TestDispatcher_WithListenerTimeout(t *testing.T) {
+	slow := &slowPushListener{cancelled: make(chan error, 1)}
+	fast := &countingPushListener{}
+	dispatcher := NewDispatcher(
+		WithListenerTimeout(20*time.Millisecond),
+		RegisterListeners(slow, fast),
+	)
+
+	err := dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{})
+	require.ErrorIs(t, err, ErrListenerTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var timeoutErr *ListenerTimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	assert.Equal(t, gitlab.EventTypePush, timeoutErr.EventType)
+	assert.Equal(t, "*gitlabwebhook.slowPushListener", timeoutErr.ListenerType)
+	assert.Equal(t, 20*time.Millisecond, timeoutErr.Timeout)
+
+	assert.ErrorIs(t, <-slow.cancelled, context.DeadlineExceeded)
+	assert.Equal(t, int64(1), fast.calls.Load())
+}
+
+func TestDispatcher_WithEventTypeTimeout(t *testing.T) {
+	slow := &slowPushListener{cancelled: make(chan error, 1)}
+	dispatcher := NewDispatcher(
+		WithListenerTimeout(time.Hour),
+		WithEventTypeTimeout(gitlab.EventTypePush, 20*time.Millisecond),
+		RegisterListeners(slow),
+	)
+
+	assert.ErrorIs(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{}), ErrListenerTimeout)
+	assert.ErrorIs(t, <-slow.cancelled, context.DeadlineExceeded)
+}
+
+func TestDispatcher_TimeoutListener(t *testing.T) {
+	slow := &slowTimeoutPushListener{slowPushListener{timeout: 20 * time.Millisecond, cancelled: make(chan error, 1)}}
+	dispatcher := NewDispatcher(
+		WithEventTypeTimeout(gitlab.EventTypePush, time.Hour),
+		RegisterListeners(slow),
+	)
+
+	assert.ErrorIs(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{}), ErrListenerTimeout)
+	assert.ErrorIs(t, <-slow.cancelled, context.DeadlineExceeded)
+}
+
+func TestDispatcher_ListenerTimeoutNotReached(t *testing.T) {
+	listener := &countingPushListener{}
+	dispatcher := NewDispatcher(WithListenerTimeout(time.Hour), RegisterListeners(listener))
+
+	assert.NoError(t, dispatcher.Dispatch(context.Background(), &gitlab.PushEvent{}))
+	assert.Equal(t, int64(1), listener.calls.Load())
+}
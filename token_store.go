@@ -0,0 +1,255 @@
+package gitlabwebhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// TokenLookup describes the delivery a token is resolved for.
+type TokenLookup struct {
+	EventType gitlab.EventType
+	// Instance is the X-Gitlab-Instance header, e.g. "https://gitlab.com".
+	Instance string
+
+	body *requestBody
+}
+
+// Payload returns the body of the delivery, which is not authenticated yet.
+// The body is only read when a store asks for it.
+func (l TokenLookup) Payload() ([]byte, error) {
+	if l.body == nil {
+		return nil, nil
+	}
+	return l.body.read()
+}
+
+// ProjectPath returns the path_with_namespace of the project the event
+// belongs to, or empty when the payload carries no project or cannot be read.
+func (l TokenLookup) ProjectPath() string {
+	payload, err := l.Payload()
+	if err != nil {
+		return ""
+	}
+	return projectPath(payload)
+}
+
+// requestBody reads the body of a delivery once, when it is first needed.
+type requestBody struct {
+	r io.Reader
+
+	once    sync.Once
+	payload []byte
+	err     error
+}
+
+func (b *requestBody) read() ([]byte, error) {
+	b.once.Do(func() {
+		b.payload, b.err = io.ReadAll(b.r)
+	})
+	return b.payload, b.err
+}
+
+// TokenStore resolves the tokens accepted for a delivery. A delivery is
+// rejected with ErrInvalidToken when its X-Gitlab-Token matches none of them.
+type TokenStore interface {
+	Tokens(ctx context.Context, lookup TokenLookup) ([]string, error)
+}
+
+// TokenStoreFunc is an adapter to allow the use of ordinary functions as a TokenStore.
+type TokenStoreFunc func(ctx context.Context, lookup TokenLookup) ([]string, error)
+
+func (f TokenStoreFunc) Tokens(ctx context.Context, lookup TokenLookup) ([]string, error) {
+	return f(ctx, lookup)
+}
+
+// TokenSet accepts any of its tokens for every delivery.
+type TokenSet []string
+
+func (s TokenSet) Tokens(context.Context, TokenLookup) ([]string, error) {
+	return s, nil
+}
+
+// TokenConfig maps projects and instances to their tokens. The most specific
+// match wins: project, then instance, then the default tokens.
+type TokenConfig struct {
+	Default   []string            `json:"default"`
+	Projects  map[string][]string `json:"projects"`
+	Instances map[string][]string `json:"instances"`
+}
+
+func (c *TokenConfig) tokens(lookup TokenLookup) []string {
+	if len(c.Projects) > 0 {
+		if path := lookup.ProjectPath(); path != "" {
+			if tokens, ok := c.Projects[path]; ok {
+				return tokens
+			}
+		}
+	}
+	if tokens, ok := c.Instances[lookup.Instance]; ok && lookup.Instance != "" {
+		return tokens
+	}
+	return c.Default
+}
+
+// MemoryTokenStore is an in-memory TokenStore that can be updated at runtime.
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	config TokenConfig
+}
+
+func NewMemoryTokenStore(config TokenConfig) *MemoryTokenStore {
+	return &MemoryTokenStore{config: config}
+}
+
+// Set replaces the tokens of the store.
+func (s *MemoryTokenStore) Set(config TokenConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+}
+
+func (s *MemoryTokenStore) Tokens(_ context.Context, lookup TokenLookup) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.tokens(lookup), nil
+}
+
+// EnvTokenStore accepts the comma-separated tokens of the environment
+// variable name. The variable is read on every delivery.
+func EnvTokenStore(name string) TokenStore {
+	return TokenStoreFunc(func(context.Context, TokenLookup) ([]string, error) {
+		var tokens []string
+		for _, token := range strings.Split(os.Getenv(name), ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+		return tokens, nil
+	})
+}
+
+// FileTokenStore reads a TokenConfig from a JSON file and reloads it whenever
+// the file's modification time changes.
+type FileTokenStore struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	config  TokenConfig
+}
+
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+func (s *FileTokenStore) Tokens(_ context.Context, lookup TokenLookup) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.ModTime().Equal(s.modTime) {
+		content, err := os.ReadFile(s.path)
+		if err != nil {
+			return nil, err
+		}
+		var config TokenConfig
+		if err := json.Unmarshal(content, &config); err != nil {
+			return nil, err
+		}
+		s.config, s.modTime = config, info.ModTime()
+	}
+
+	return s.config.tokens(lookup), nil
+}
+
+// RotatingTokenStore accepts its current token and, for a grace period after
+// each rotation, the tokens it replaced.
+type RotatingTokenStore struct {
+	mu       sync.RWMutex
+	current  string
+	previous map[string]time.Time
+	now      func() time.Time
+}
+
+func NewRotatingTokenStore(current string) *RotatingTokenStore {
+	return &RotatingTokenStore{
+		current:  current,
+		previous: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Rotate makes next the current token and keeps accepting the replaced token
+// for grace.
+func (s *RotatingTokenStore) Rotate(next string, grace time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for token, expiry := range s.previous {
+		if !now.Before(expiry) {
+			delete(s.previous, token)
+		}
+	}
+	if s.current != "" && s.current != next {
+		s.previous[s.current] = now.Add(grace)
+	}
+	delete(s.previous, next)
+	s.current = next
+}
+
+func (s *RotatingTokenStore) Tokens(context.Context, TokenLookup) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := s.now()
+	tokens := []string{s.current}
+	for token, expiry := range s.previous {
+		if now.Before(expiry) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+type tokenPayload struct {
+	Project *struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+func projectPath(payload []byte) string {
+	var p tokenPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return ""
+	}
+	if p.Project != nil && p.Project.PathWithNamespace != "" {
+		return p.Project.PathWithNamespace
+	}
+	return p.PathWithNamespace
+}
+
+// matchToken compares token against every candidate in constant time, so
+// neither the matching token nor its position leaks through timing.
+func matchToken(token string, candidates []string) bool {
+	match := 0
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		match |= subtle.ConstantTimeCompare([]byte(token), []byte(candidate))
+	}
+	return match == 1
+}
@@ -0,0 +1,151 @@
+package gitlabwebhook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestDispatcher_DispatchRequestWithTokenStore(t *testing.T) {
+	store := NewMemoryTokenStore(TokenConfig{
+		Default:   []string{"default-token"},
+		Projects:  map[string][]string{"mike/diaspora": {"project-token", "next-project-token"}},
+		Instances: map[string][]string{"https://gitlab.example.com": {"instance-token"}},
+	})
+
+	tests := []struct {
+		name          string
+		fixture       string
+		eventType     gitlab.EventType
+		instance      string
+		token         string
+		expectedError error
+	}{
+		{"project token", "testdata/webhooks/push.json", gitlab.EventTypePush, "", "project-token", nil},
+		{"rotated project token", "testdata/webhooks/push.json", gitlab.EventTypePush, "", "next-project-token", nil},
+		{"default token rejected for configured project", "testdata/webhooks/push.json", gitlab.EventTypePush, "", "default-token", ErrInvalidToken},                       //nolint:lll
+		{"default token for other project", "testdata/webhooks/release.json", gitlab.EventTypeRelease, "", "default-token", nil},                                           //nolint:lll
+		{"instance token", "testdata/webhooks/release.json", gitlab.EventTypeRelease, "https://gitlab.example.com", "instance-token", nil},                                 //nolint:lll
+		{"missing token", "testdata/webhooks/release.json", gitlab.EventTypeRelease, "", "", ErrInvalidToken},                                                              //nolint:lll
+		{"instance token rejected for other instance", "testdata/webhooks/release.json", gitlab.EventTypeRelease, "https://gitlab.com", "instance-token", ErrInvalidToken}, //nolint:lll
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispatcher := NewDispatcher()
+
+			req, err := http.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(loadFixture(tt.fixture)))
+			require.NoError(t, err)
+			req.Header.Set("X-Gitlab-Event", string(tt.eventType))
+			req.Header.Set("X-Gitlab-Instance", tt.instance)
+			req.Header.Set("X-Gitlab-Token", tt.token)
+
+			err = dispatcher.DispatchRequest(req, DispatchRequestWithTokenStore(store))
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEnvTokenStore(t *testing.T) {
+	t.Setenv("GITLAB_WEBHOOK_TOKENS", "first, second,,")
+
+	tokens, err := EnvTokenStore("GITLAB_WEBHOOK_TOKENS").Tokens(context.Background(), TokenLookup{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, tokens)
+}
+
+func TestFileTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"default":["one"],"projects":{"a/b":["two"]}}`), 0o600))
+
+	store := NewFileTokenStore(path)
+	lookup := tokenLookup(`{"project":{"path_with_namespace":"a/b"}}`)
+	tokens, err := store.Tokens(context.Background(), lookup)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"two"}, tokens)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"default":["three"]}`), 0o600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+
+	tokens, err = store.Tokens(context.Background(), lookup)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"three"}, tokens)
+}
+
+func TestRotatingTokenStore(t *testing.T) {
+	now := time.Now()
+	store := NewRotatingTokenStore("old")
+	store.now = func() time.Time { return now }
+
+	store.Rotate("new", time.Hour)
+	tokens, err := store.Tokens(context.Background(), TokenLookup{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"new", "old"}, tokens)
+
+	now = now.Add(2 * time.Hour)
+	tokens, err = store.Tokens(context.Background(), TokenLookup{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new"}, tokens)
+}
+
+func TestTokenLookup_ProjectPath(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{"project", `{"project":{"path_with_namespace":"a/b"}}`, "a/b"},
+		{"project event", `{"event_name":"project_create","path_with_namespace":"a/c"}`, "a/c"},
+		{"no project", `{"object_kind":"build"}`, ""},
+		{"invalid", `{`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tokenLookup(tt.payload).ProjectPath())
+		})
+	}
+}
+
+func TestDispatcher_DispatchRequestTokenBeforeBody(t *testing.T) {
+	dispatcher := NewDispatcher()
+
+	body := &countingReader{r: bytes.NewReader(loadFixture("testdata/webhooks/push.json"))}
+	req, err := http.NewRequest(http.MethodPost, "/webhook", body)
+	require.NoError(t, err)
+	req.Header.Set("X-Gitlab-Event", string(gitlab.EventTypePush))
+	req.Header.Set("X-Gitlab-Token", "wrong-token")
+
+	err = dispatcher.DispatchRequest(req, DispatchRequestWithToken("token"))
+	require.ErrorIs(t, err, ErrInvalidToken)
+	assert.Zero(t, body.n, "body read before the token was checked")
+}
+
+func tokenLookup(payload string) TokenLookup {
+	return TokenLookup{body: &requestBody{r: strings.NewReader(payload)}}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
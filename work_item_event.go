@@ -0,0 +1,62 @@
+package gitlabwebhook
+
+import (
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// The gitlab client library decodes every Issue Hook as an IssueEvent, even
+// when GitLab sends it for a work item, so we define WorkItemEvent here and
+// register a decoder for its object_kind.
+
+const objectKindWorkItem = "work_item"
+
+// WorkItemEvent represents a work item event from GitLab, delivered as an
+// Issue Hook with object_kind "work_item"
+type WorkItemEvent struct {
+	ObjectKind       string                    `json:"object_kind"`
+	EventType        string                    `json:"event_type"`
+	User             *gitlab.EventUser         `json:"user"`
+	Project          *gitlab.IssueEventProject `json:"project,omitempty"`
+	Repository       *gitlab.Repository        `json:"repository,omitempty"`
+	ObjectAttributes *WorkItemAttributes       `json:"object_attributes"`
+	Assignees        []*gitlab.EventUser       `json:"assignees"`
+	Labels           []*gitlab.EventLabel      `json:"labels"`
+	Changes          gitlab.IssueEventChanges  `json:"changes"`
+}
+
+// WorkItemAttributes represents the attributes of the work item
+type WorkItemAttributes struct {
+	ID           int                  `json:"id"`
+	IID          int                  `json:"iid"`
+	Title        string               `json:"title"`
+	Description  string               `json:"description"`
+	Type         string               `json:"type"` // Task, Epic, Objective, Key Result, ...
+	State        string               `json:"state"`
+	StateID      int                  `json:"state_id"`
+	Action       string               `json:"action"`
+	URL          string               `json:"url"`
+	Confidential bool                 `json:"confidential"`
+	AuthorID     int                  `json:"author_id"`
+	ProjectID    int                  `json:"project_id"` // zero for group-level work items such as epics
+	NamespaceID  int                  `json:"namespace_id"`
+	MilestoneID  *int                 `json:"milestone_id"`
+	AssigneeIDs  []ID                 `json:"assignee_ids"`
+	Labels       []*gitlab.EventLabel `json:"labels"`
+	Weight       *int                 `json:"weight"`
+	HealthStatus *string              `json:"health_status"`
+	DueDate      *string              `json:"due_date"`
+	CreatedAt    *FlexibleTime        `json:"created_at"`
+	UpdatedAt    *FlexibleTime        `json:"updated_at"`
+	ClosedAt     *FlexibleTime        `json:"closed_at"`
+	Status       *IssueStatus         `json:"status"`
+	Parent       *WorkItemParent      `json:"parent"`
+}
+
+// WorkItemParent represents the parent of a work item in the work item hierarchy
+type WorkItemParent struct {
+	ID    int    `json:"id"`
+	IID   int    `json:"iid"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+}